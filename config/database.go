@@ -1,9 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Config struct {
@@ -14,7 +16,137 @@ type Config struct {
 		Password string `yaml:"password"`
 		DBName   string `yaml:"dbname"`
 	} `yaml:"database"`
-	Admins []string `yaml:"admins"` // 添加管理员邮箱列表
+	Admins []string  `yaml:"admins"` // 添加管理员邮箱列表
+	JWT    JWTConfig `yaml:"jwt"`
+	// 新建用户的默认设置
+	AccountDefaults AccountDefaults `yaml:"account_defaults"`
+	// 与 Dify 共用的文件存储，用于保存工作空间私钥
+	Storage StorageConfig `yaml:"storage"`
+	// 邀请邮件的发送方式
+	Mail MailConfig `yaml:"mail"`
+	// 邀请链接的地址和有效期
+	Invitation InvitationConfig `yaml:"invitation"`
+	// 设置密码时的安全策略
+	PasswordPolicy PasswordPolicy `yaml:"password_policy"`
+	// 登录失败次数限制
+	LoginThrottle LoginThrottleConfig `yaml:"login_throttle"`
+	// 可信的反向代理地址，只有来自这些地址的请求才使用 X-Forwarded-For 中的客户端 IP，为空时直接使用连接地址
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// LoginThrottleConfig 登录失败限制，按来源 IP 和邮箱分别统计连续失败次数
+// 每次失败后需等待 base_delay 秒再重试，之后每次翻倍，达到 max_failures 次后锁定 lockout_minutes 分钟
+type LoginThrottleConfig struct {
+	Disabled       bool `yaml:"disabled"`
+	MaxFailures    int  `yaml:"max_failures"`    // 同一邮箱连续失败多少次后锁定，默认 5
+	IPMaxFailures  int  `yaml:"ip_max_failures"` // 同一 IP 连续失败多少次后锁定，默认 20
+	BaseDelay      int  `yaml:"base_delay"`      // 首次失败后的等待时间（秒），默认 1
+	MaxDelay       int  `yaml:"max_delay"`       // 等待时间上限（秒），默认 60
+	LockoutMinutes int  `yaml:"lockout_minutes"` // 锁定时长（分钟），默认 15
+	WindowMinutes  int  `yaml:"window_minutes"`  // 超过该时间没有再失败时重新计数（分钟），默认 15
+}
+
+// PasswordPolicy 密码安全策略，未配置的字段使用 defaultPasswordPolicy 中的值
+type PasswordPolicy struct {
+	MinLength     int      `yaml:"min_length"`     // 最小长度
+	RequireLetter bool     `yaml:"require_letter"` // 必须包含字母
+	RequireUpper  bool     `yaml:"require_upper"`  // 必须包含大写字母
+	RequireLower  bool     `yaml:"require_lower"`  // 必须包含小写字母
+	RequireDigit  bool     `yaml:"require_digit"`  // 必须包含数字
+	RequireSymbol bool     `yaml:"require_symbol"` // 必须包含特殊字符
+	Denylist      []string `yaml:"denylist"`       // 禁止使用的密码，不区分大小写，内置常见弱密码之外的补充
+	DenylistFile  string   `yaml:"denylist_file"`  // 禁止使用的密码文件，每行一个
+	History       int      `yaml:"history"`        // 不能与最近 N 次使用过的密码相同，0 表示不限制
+	MaxAgeDays    int      `yaml:"max_age_days"`   // 密码有效期（天），0 表示不过期
+}
+
+// defaultPasswordPolicy 默认的密码安全策略，与 Dify 一致：至少 8 位，包含字母和数字，并且不能与最近 5 次的密码相同
+var defaultPasswordPolicy = PasswordPolicy{
+	MinLength:     8,
+	RequireLetter: true,
+	RequireDigit:  true,
+	History:       5,
+}
+
+// 邮件发送方式
+const (
+	MailTypeSMTP = "smtp"
+	MailTypeFile = "file"
+	MailTypeLog  = "log"
+)
+
+// MailConfig 邮件配置，file 和 log 用于测试，不会真正发送邮件
+type MailConfig struct {
+	Type string     `yaml:"type"` // smtp、file 或 log，默认 log
+	From string     `yaml:"from"` // 发件人地址
+	SMTP SMTPConfig `yaml:"smtp"`
+	File FileConfig `yaml:"file"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	TLS      string `yaml:"tls"` // starttls（默认）、tls（端口 465 等直接使用 TLS）或 none
+}
+
+type FileConfig struct {
+	Path string `yaml:"path"` // 邮件保存为 .eml 文件的目录
+}
+
+// InvitationConfig 邀请链接配置
+type InvitationConfig struct {
+	BaseURL string `yaml:"base_url"` // DifyServer 的访问地址，激活链接为 <base_url>/activate?token=...
+	TTL     int    `yaml:"ttl"`      // 邀请链接有效期（小时）
+}
+
+// 存储类型
+const (
+	StorageTypeLocal = "local"
+	StorageTypeS3    = "s3"
+)
+
+// StorageConfig 文件存储配置，需要与 Dify 的存储配置指向同一位置
+type StorageConfig struct {
+	Type  string      `yaml:"type"` // local 或 s3，默认 local
+	Local LocalConfig `yaml:"local"`
+	S3    S3Config    `yaml:"s3"`
+}
+
+type LocalConfig struct {
+	Path string `yaml:"path"` // 对应 Dify 的 OPENDAL_FS_ROOT / STORAGE_LOCAL_PATH
+}
+
+// S3Config S3 兼容对象存储的配置，对应 Dify 的 S3_* 配置
+type S3Config struct {
+	Endpoint     string `yaml:"endpoint"`
+	Region       string `yaml:"region"`
+	Bucket       string `yaml:"bucket"`
+	AccessKey    string `yaml:"access_key"`
+	SecretKey    string `yaml:"secret_key"`
+	UsePathStyle bool   `yaml:"use_path_style"`
+}
+
+// AccountDefaults 新建用户时未指定的字段使用的默认值
+type AccountDefaults struct {
+	InterfaceLanguage string `yaml:"interface_language"`
+	Timezone          string `yaml:"timezone"`
+	InterfaceTheme    string `yaml:"interface_theme"`
+	Avatar            string `yaml:"avatar"`
+}
+
+// JWTConfig 登录 token 的签名密钥和有效期配置
+type JWTConfig struct {
+	Keys            []JWTKey `yaml:"keys"`              // 签名密钥列表，可同时保留多个用于轮换
+	ActiveKey       string   `yaml:"active_key"`        // 签发新 token 使用的密钥ID，为空时使用第一个
+	AccessTokenTTL  int      `yaml:"access_token_ttl"`  // 访问 token 有效期（分钟）
+	RefreshTokenTTL int      `yaml:"refresh_token_ttl"` // 刷新 token 有效期（小时）
+}
+
+type JWTKey struct {
+	ID     string `yaml:"id"`
+	Secret string `yaml:"secret"`
 }
 
 var GlobalConfig Config
@@ -33,11 +165,129 @@ func LoadConfig() error {
 		return err
 	}
 
-	// 解析配置文件
+	// 解析配置文件，配置文件中没有写的字段保留默认值，写了 false 或 0 时以配置为准
+	GlobalConfig.PasswordPolicy = defaultPasswordPolicy
 	err = yaml.Unmarshal(data, &GlobalConfig)
 	if err != nil {
 		return err
 	}
 
+	loadEnv()
+	applyDefaults()
+
+	return loadDenylist()
+}
+
+// loadDenylist 读取 denylist_file 中禁止使用的密码，合并到 Denylist
+func loadDenylist() error {
+	policy := &GlobalConfig.PasswordPolicy
+	if policy.DenylistFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(policy.DenylistFile)
+	if err != nil {
+		return fmt.Errorf("读取密码黑名单失败: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			policy.Denylist = append(policy.Denylist, line)
+		}
+	}
 	return nil
 }
+
+// applyDefaults 为未配置的项设置默认值
+func applyDefaults() {
+	defaults := &GlobalConfig.AccountDefaults
+	if defaults.InterfaceLanguage == "" {
+		defaults.InterfaceLanguage = "zh-Hans"
+	}
+	if defaults.Timezone == "" {
+		defaults.Timezone = "Asia/Shanghai"
+	}
+	if defaults.InterfaceTheme == "" {
+		defaults.InterfaceTheme = "light"
+	}
+
+	if GlobalConfig.Storage.Type == "" {
+		GlobalConfig.Storage.Type = StorageTypeLocal
+	}
+	if GlobalConfig.Storage.Local.Path == "" {
+		GlobalConfig.Storage.Local.Path = "storage"
+	}
+
+	if GlobalConfig.Mail.Type == "" {
+		GlobalConfig.Mail.Type = MailTypeLog
+	}
+	if GlobalConfig.Mail.SMTP.TLS == "" {
+		GlobalConfig.Mail.SMTP.TLS = "starttls"
+	}
+	if GlobalConfig.Mail.SMTP.Port == 0 {
+		GlobalConfig.Mail.SMTP.Port = 587
+	}
+	if GlobalConfig.Mail.File.Path == "" {
+		GlobalConfig.Mail.File.Path = "mails"
+	}
+	if GlobalConfig.Invitation.BaseURL == "" {
+		GlobalConfig.Invitation.BaseURL = "http://localhost:8080"
+	}
+	if GlobalConfig.Invitation.TTL <= 0 {
+		GlobalConfig.Invitation.TTL = 72
+	}
+
+	if GlobalConfig.PasswordPolicy.MinLength <= 0 {
+		GlobalConfig.PasswordPolicy.MinLength = defaultPasswordPolicy.MinLength
+	}
+
+	throttle := &GlobalConfig.LoginThrottle
+	if throttle.MaxFailures <= 0 {
+		throttle.MaxFailures = 5
+	}
+	if throttle.IPMaxFailures <= 0 {
+		throttle.IPMaxFailures = 20
+	}
+	if throttle.BaseDelay <= 0 {
+		throttle.BaseDelay = 1
+	}
+	if throttle.MaxDelay <= 0 {
+		throttle.MaxDelay = 60
+	}
+	if throttle.LockoutMinutes <= 0 {
+		throttle.LockoutMinutes = 15
+	}
+	if throttle.WindowMinutes <= 0 {
+		throttle.WindowMinutes = 15
+	}
+}
+
+// loadEnv 从环境变量读取敏感配置，环境变量优先于配置文件
+func loadEnv() {
+	// DIFYSERVER_JWT_SECRET 作为当前签名密钥，DIFYSERVER_JWT_KEY_ID 为其ID（默认 env）
+	if secret := os.Getenv("DIFYSERVER_JWT_SECRET"); secret != "" {
+		keyID := os.Getenv("DIFYSERVER_JWT_KEY_ID")
+		if keyID == "" {
+			keyID = "env"
+		}
+		keys := []JWTKey{{ID: keyID, Secret: secret}}
+		for _, key := range GlobalConfig.JWT.Keys {
+			if key.ID != keyID {
+				keys = append(keys, key)
+			}
+		}
+		GlobalConfig.JWT.Keys = keys
+		GlobalConfig.JWT.ActiveKey = keyID
+	}
+
+	// 对象存储的密钥
+	if accessKey := os.Getenv("DIFYSERVER_S3_ACCESS_KEY"); accessKey != "" {
+		GlobalConfig.Storage.S3.AccessKey = accessKey
+	}
+	if secretKey := os.Getenv("DIFYSERVER_S3_SECRET_KEY"); secretKey != "" {
+		GlobalConfig.Storage.S3.SecretKey = secretKey
+	}
+
+	// 邮件服务器密码
+	if password := os.Getenv("DIFYSERVER_SMTP_PASSWORD"); password != "" {
+		GlobalConfig.Mail.SMTP.Password = password
+	}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"difyserver/config"
+	"difyserver/models"
 	"fmt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -23,5 +24,10 @@ func InitDB() error {
 		return err
 	}
 
+	// 只迁移 DifyServer 自有的表，Dify 原有表结构由 Dify 维护
+	if err := DB.AutoMigrate(&models.AdminRole{}, &models.AdminTenant{}, &models.AuditLog{}, &models.RevokedToken{}, &models.Invitation{}, &models.PasswordHistory{}, &models.LoginThrottle{}); err != nil {
+		return err
+	}
+
 	return nil
 }
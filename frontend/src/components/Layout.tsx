@@ -7,6 +7,7 @@ import Tenants from '../pages/Tenants';
 import TenantAccounts from '../pages/TenantAccounts';
 import Datasets from '../pages/Datasets';
 import Login from '../pages/Login';
+import Activate from '../pages/Activate';
 
 const { Sider, Content } = Layout;
 
@@ -16,6 +17,15 @@ const MainLayout: React.FC = () => {
   // 检查是否已登录
   const isAuthenticated = localStorage.getItem('user') !== null;
 
+  // 激活页面由被邀请的用户打开，不需要登录
+  if (location.pathname === '/activate') {
+    return (
+      <Routes>
+        <Route path="/activate" element={<Activate />} />
+      </Routes>
+    );
+  }
+
   // 如果未登录，只渲染登录页面
   if (!isAuthenticated) {
     return (
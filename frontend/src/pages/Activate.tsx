@@ -0,0 +1,117 @@
+import React from 'react';
+import { Form, Input, Button, Card, Result, Spin, message } from 'antd';
+import { useNavigate, useSearchParams } from 'react-router-dom';
+import { invitationApi } from '../services/api';
+import { InvitationInfo } from '../types';
+
+// 被邀请的用户通过邮件中的链接打开该页面，设置密码后激活账号
+const Activate: React.FC = () => {
+  const navigate = useNavigate();
+  const [searchParams] = useSearchParams();
+  const token = searchParams.get('token') || '';
+  const [info, setInfo] = React.useState<InvitationInfo | null>(null);
+  const [error, setError] = React.useState('');
+  const [loading, setLoading] = React.useState(true);
+  const [done, setDone] = React.useState(false);
+
+  React.useEffect(() => {
+    invitationApi.getInvitation(token)
+      .then(response => setInfo(response.data))
+      .catch(e => setError(e.response?.data?.error || '邀请链接无效或已过期'))
+      .finally(() => setLoading(false));
+  }, [token]);
+
+  const onFinish = async (values: { name: string; password: string }) => {
+    try {
+      await invitationApi.acceptInvitation({ token, password: values.password, name: values.name });
+      setDone(true);
+    } catch (e: any) {
+      message.error(e.response?.data?.error || '激活失败，请稍后重试');
+    }
+  };
+
+  let content: React.ReactNode;
+  if (loading) {
+    content = <Spin />;
+  } else if (done) {
+    content = <Result status="success" title="账号激活成功" subTitle="现在可以使用邮箱和新密码登录 Dify" />;
+  } else if (error || !info) {
+    content = (
+      <Result
+        status="warning"
+        title={error || '邀请链接无效或已过期'}
+        subTitle="请联系管理员重新发送邀请"
+        extra={<Button onClick={() => navigate('/login')}>返回</Button>}
+      />
+    );
+  } else {
+    content = (
+      <Form
+        name="activate"
+        onFinish={onFinish}
+        layout="vertical"
+        initialValues={{ name: info.Name }}
+      >
+        <p>
+          {info.TenantName ? `邀请您加入工作空间「${info.TenantName}」，` : ''}
+          登录邮箱为 {info.Email}
+        </p>
+        <Form.Item name="name" label="用户名">
+          <Input />
+        </Form.Item>
+
+        <Form.Item
+          name="password"
+          label="密码"
+          rules={[
+            { required: true, message: '请输入密码' },
+            { min: 8, message: '密码长度至少为8位' }
+          ]}
+        >
+          <Input.Password />
+        </Form.Item>
+
+        <Form.Item
+          name="confirm"
+          label="确认密码"
+          dependencies={['password']}
+          rules={[
+            { required: true, message: '请再次输入密码' },
+            ({ getFieldValue }) => ({
+              validator(_, value) {
+                if (!value || getFieldValue('password') === value) {
+                  return Promise.resolve();
+                }
+                return Promise.reject(new Error('两次输入的密码不一致'));
+              },
+            }),
+          ]}
+        >
+          <Input.Password />
+        </Form.Item>
+
+        <Form.Item>
+          <Button type="primary" htmlType="submit" block>
+            激活账号
+          </Button>
+        </Form.Item>
+      </Form>
+    );
+  }
+
+  return (
+    <div style={{
+      display: 'flex',
+      justifyContent: 'center',
+      alignItems: 'center',
+      height: '100vh',
+      background: '#f0f2f5'
+    }}>
+      <Card title="激活账号" style={{ width: 400 }}>
+        {content}
+      </Card>
+    </div>
+  );
+};
+
+export default Activate;
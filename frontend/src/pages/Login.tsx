@@ -34,7 +34,8 @@ const Login: React.FC = () => {
         }, 1000);
       }
     } catch (error: any) {
-      if (error.response?.status === 401) {
+      // 401 用户名或密码错误，403 密码已过期，429 失败次数过多
+      if ([401, 403, 429].includes(error.response?.status)) {
         message.error(error.response.data.error);
       } else {
         message.error('登录失败，请稍后重试');
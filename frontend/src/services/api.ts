@@ -17,10 +17,26 @@ api.interceptors.request.use(config => {
     return config;
 });
 
-// 添加响应拦截器
+// 添加响应拦截器，访问 token 过期时先尝试用刷新 token 换取新 token
 api.interceptors.response.use(
     response => response,
-    error => {
+    async error => {
+        const original = error.config;
+        const userStr = localStorage.getItem('user');
+        if (error.response?.status === 401 && userStr && !original._retry) {
+            original._retry = true;
+            const user = JSON.parse(userStr);
+            if (user.refresh_token) {
+                try {
+                    const response = await axios.post('/api/refresh_token.json', { refresh_token: user.refresh_token });
+                    localStorage.setItem('user', JSON.stringify({ ...user, ...response.data }));
+                    original.headers.Authorization = `Bearer ${response.data.token}`;
+                    return api(original);
+                } catch (e) {
+                    // 刷新失败时重新登录
+                }
+            }
+        }
         if (error.response?.status === 401) {
             localStorage.removeItem('user');
             window.location.href = '/login';
@@ -29,33 +45,169 @@ api.interceptors.response.use(
     }
 );
 
+// 列表接口的通用查询参数：page_size、search、sort、order、created_from、created_to 以及各接口的筛选字段
+export type ListParams = Record<string, string | number | undefined>;
+
 export const accountApi = {
     login: (data: { email: string; password: string }) =>
         api.post('/login.json', data),
-    getAccounts: (page: number) =>
-        api.get('/accounts.json', { params: { page } }),
-    addAccount: (data: { name: string; email: string }) =>
+    logout: (refreshToken?: string) =>
+        api.post('/logout.json', { refresh_token: refreshToken }),
+    getAccounts: (page: number, params?: ListParams) =>
+        api.get('/accounts.json', { params: { page, ...params } }),
+    addAccount: (data: { name: string; email: string; password?: string; tenants?: { tenant_id: string; role: string }[] }) =>
         api.post('/add_account.json', data),
     deleteAccount: (id: string) =>
         api.post('/del_account.json', { id }),
     setPassword: (id: string, password: string) =>
         api.post('/set_account_password.json', { id, password }),
+    getExpiredPasswords: (page: number, params?: ListParams) =>
+        api.get('/expired_passwords.json', { params: { page, ...params } }),
+    // 不需要登录，密码过期后也可以使用
+    changePassword: (data: { email: string; password: string; new_password: string }) =>
+        api.post('/change_password.json', data),
+    getLoginThrottles: (page: number, params?: ListParams) =>
+        api.get('/login_throttles.json', { params: { page, ...params } }),
+    clearLoginThrottle: (scope: 'ip' | 'email', value: string) =>
+        api.post('/clear_login_throttle.json', { scope, value }),
+};
+
+export const invitationApi = {
+    inviteAccount: (data: {
+        email: string;
+        name?: string;
+        interface_language?: string;
+        timezone?: string;
+        tenants?: { tenant_id: string; role: string }[];
+    }) =>
+        api.post('/invite_account.json', data),
+    resendInvitation: (accountId: string) =>
+        api.post('/resend_invitation.json', { account_id: accountId }),
+    revokeInvitation: (id: string) =>
+        api.post('/revoke_invitation.json', { id }),
+    getInvitations: (page: number, params?: ListParams) =>
+        api.get('/invitations.json', { params: { page, ...params } }),
+    // 以下两个接口不需要登录
+    getInvitation: (token: string) =>
+        api.get('/invitation.json', { params: { token } }),
+    acceptInvitation: (data: { token: string; password: string; name?: string }) =>
+        api.post('/accept_invitation.json', data),
 };
 
 export const tenantApi = {
-    getTenants: (page: number) =>
-        api.get('/tenants.json', { params: { page } }),
+    getTenants: (page: number, params?: ListParams) =>
+        api.get('/tenants.json', { params: { page, ...params } }),
     addTenant: (data: { 
         name: string; 
         plan: 'basic' | 'pro' | 'enterprise';
         status: string;
+        owner_id?: string;
     }) =>
         api.post('/add_tenant.json', data),
+    cloneTenant: (data: {
+        source_tenant_id: string;
+        name: string;
+        owner_id?: string;
+        member_ids?: string[];
+        app_ids?: string[];
+        dataset_ids?: string[];
+    }) =>
+        api.post('/clone_tenant.json', data),
+};
+
+export const appApi = {
+    getApps: (page: number, params?: ListParams) =>
+        api.get('/apps.json', { params: { page, ...params } }),
+    moveApp: (appId: string, tenantId: string, dryRun = false) =>
+        api.post('/move_app.json', { app_id: appId, tenant_id: tenantId, dry_run: dryRun }),
+    setAppAccess: (data: { app_id: string; enable_site?: boolean; enable_api?: boolean }) =>
+        api.post('/set_app_access.json', data),
+};
+
+export const apiTokenApi = {
+    getApiTokens: (page: number, params?: ListParams & {
+        token?: string;
+        last_used_before?: string;
+        app_created_by?: string;
+        created_by?: string;
+    }) =>
+        api.get('/api_tokens.json', { params: { page, ...params } }),
+    addApiToken: (data: { type: 'app' | 'dataset'; app_id?: string; tenant_id?: string }) =>
+        api.post('/add_api_token.json', data),
+    revokeApiTokens: (ids: string[]) =>
+        api.post('/revoke_api_tokens.json', { ids }),
+};
+
+export const providerApi = {
+    getProviders: (page: number, params?: ListParams & {
+        tenant_id?: string;
+        provider_name?: string;
+        provider_type?: string;
+        is_valid?: boolean;
+    }) =>
+        api.get('/providers.json', { params: { page, ...params } }),
+    getProviderModels: (page: number, params?: ListParams & {
+        tenant_id?: string;
+        provider_name?: string;
+        model_type?: string;
+        is_valid?: boolean;
+    }) =>
+        api.get('/provider_models.json', { params: { page, ...params } }),
+    getTenantDefaultModels: (page: number, params?: ListParams & { tenant_id?: string; model_type?: string }) =>
+        api.get('/tenant_default_models.json', { params: { page, ...params } }),
+    setTenantDefaultModel: (data: {
+        tenant_id: string;
+        model_type: 'llm' | 'text-embedding' | 'rerank' | 'speech2text' | 'tts' | 'moderation';
+        provider_name: string;
+        model_name: string;
+    }) =>
+        api.post('/set_tenant_default_model.json', data),
+    copyProvider: (data: {
+        source_tenant_id: string;
+        target_tenant_id: string;
+        provider_name: string;
+        overwrite?: boolean;
+    }) =>
+        api.post('/copy_provider.json', data),
+};
+
+export interface ReportParams {
+    group_by?: 'tenant' | 'app' | 'account' | 'day' | 'month';
+    start?: string;
+    end?: string;
+    timezone?: string;
+    tenant_id?: string;
+    app_id?: string;
+    account_id?: string;
+}
+
+export const reportApi = {
+    getMessageReport: (params?: ReportParams) =>
+        api.get('/reports/messages.json', { params }),
+    getWorkflowRunReport: (params?: ReportParams) =>
+        api.get('/reports/workflow_runs.json', { params }),
+};
+
+export interface AuditLogParams {
+    page_size?: number;
+    // 操作人ID或邮箱
+    actor?: string;
+    action?: string;
+    target_type?: string;
+    target_id?: string;
+    tenant_id?: string;
+    created_from?: string;
+    created_to?: string;
+}
+
+export const auditApi = {
+    getAuditLogs: (page: number, params?: AuditLogParams) =>
+        api.get('/audit_logs.json', { params: { page, ...params } }),
 };
 
 export const datasetApi = {
-    getDatasets: (page: number) =>
-        api.get('/datasets.json', { params: { page } }),
+    getDatasets: (page: number, params?: ListParams) =>
+        api.get('/datasets.json', { params: { page, ...params } }),
     addDataset: (data: {
         name: string;
         description: string;
@@ -66,6 +218,18 @@ export const datasetApi = {
         api.post('/add_dataset.json', data),
     addDatasetTenant: (datasetId: string, tenantId: string) =>
         api.post('/add_dataset_tenant.json', { dataset_id: datasetId, tenant_id: tenantId }),
+    getDatasetDetail: (datasetId: string) =>
+        api.get('/dataset_detail.json', { params: { dataset_id: datasetId } }),
+    getDocuments: (datasetId: string, page: number, params?: ListParams) =>
+        api.get('/documents.json', { params: { dataset_id: datasetId, page, ...params } }),
+    getDocumentSegments: (documentId: string, page: number, params?: ListParams) =>
+        api.get('/document_segments.json', { params: { document_id: documentId, page, ...params } }),
+    getDatasetPermission: (datasetId: string) =>
+        api.get('/dataset_permission.json', { params: { dataset_id: datasetId } }),
+    setDatasetPermission: (data: { dataset_id: string; permission: string; account_ids?: string[] }) =>
+        api.post('/set_dataset_permission.json', data),
+    moveDataset: (datasetId: string, tenantId: string, dryRun = false) =>
+        api.post('/move_dataset.json', { dataset_id: datasetId, tenant_id: tenantId, dry_run: dryRun }),
     deleteDatasetTenant: (datasetId: string, tenantId: string) =>
         api.post('/del_dataset_tenant.json', { dataset_id: datasetId, tenant_id: tenantId }),
     listDatasetTenant: () =>
@@ -73,18 +237,22 @@ export const datasetApi = {
 };
 
 export const tenantAccountApi = {
-    listTenantAccounts: (page: number) =>
-        api.get('/list_tenant_account.json', { params: { page } }),
-    listByAccount: (accountId: string, page: number) =>
-        api.get('/list_tenant_account_by_account.json', { params: { account_id: accountId, page } }),
-    listByTenant: (tenantId: string, page: number) =>
-        api.get('/list_tenant_account_by_tenant.json', { params: { tenant_id: tenantId, page } }),
+    listTenantAccounts: (page: number, params?: ListParams) =>
+        api.get('/list_tenant_account.json', { params: { page, ...params } }),
+    listByAccount: (accountId: string, page: number, params?: ListParams) =>
+        api.get('/list_tenant_account_by_account.json', { params: { account_id: accountId, page, ...params } }),
+    listByTenant: (tenantId: string, page: number, params?: ListParams) =>
+        api.get('/list_tenant_account_by_tenant.json', { params: { tenant_id: tenantId, page, ...params } }),
     addTenantAccount: (data: { account_id: string; tenant_id: string; role: string }) =>
         api.post('/add_tenant_account.json', data),
     deleteTenantAccount: (accountId: string, tenantId: string) =>
         api.post('/del_tenant_account.json', { account_id: accountId, tenant_id: tenantId }),
     updateRole: (data: { account_id: string; tenant_id: string; role: string }) =>
         api.post('/update_tenant_account_role.json', data),
+    transferOwner: (data: { account_id: string; tenant_id: string }) =>
+        api.post('/transfer_tenant_owner.json', data),
+    checkConsistency: () =>
+        api.get('/check_tenant_consistency.json'),
 };
 
 // 修改最后的导出语句，只导出未使用 export const 导出的内容
@@ -2,13 +2,19 @@ export interface Account {
     ID: string;
     Name: string;
     Email: string;
-    Password?: string;
-    PasswordSalt?: string;
     Avatar: string | null;
     InterfaceLanguage: string;
     InterfaceTheme: string;
     Timezone: string;
     Status: string;
+    HasPassword: boolean;
+    TenantCount: number;
+    InitializedAt: string | null;
+    LastLoginAt: string | null;
+    LastLoginIP: string | null;
+    LastActiveAt: string;
+    CreatedAt: string;
+    UpdatedAt: string;
   }
 
 export interface PageResponse<T> {
@@ -22,12 +28,12 @@ export interface PageResponse<T> {
 export interface Tenant {
   ID: string;
   Name: string;
-  EncryptPublicKey: string;
   Plan: string;
   Status: string;
   CreatedAt: string;
   UpdatedAt: string;
   CustomConfig: any;
+  MemberCount: number;
 }
 
 
@@ -40,15 +46,12 @@ export interface Dataset {
   Permission: string;
   DataSourceType: string;
   IndexingTechnique: string;
-  IndexStruct: string;
   CreatedBy: string;
   CreatedAt: string;
   UpdatedBy: string;
   UpdatedAt: string;
   EmbeddingModel: string;
   EmbeddingModelProvider: string;
-  CollectionBindingID: string;
-  RetrievalModel: string;
 }
 
 
@@ -61,4 +64,168 @@ export interface TenantAccountJoin {
   CreatedAt: string;
   UpdatedAt: string;
   Current: boolean;
-}
\ No newline at end of file
+  AccountEmail: string;
+  AccountName: string;
+  AccountStatus: string;
+  TenantName: string;
+  TenantPlan: string;
+  TenantStatus: string;
+}
+export interface Document {
+  ID: string;
+  TenantID: string;
+  DatasetID: string;
+  Position: number;
+  Name: string;
+  DataSourceType: string;
+  CreatedFrom: string;
+  IndexingStatus: string;
+  WordCount: number | null;
+  Tokens: number | null;
+  Error: string | null;
+  Enabled: boolean;
+  Archived: boolean;
+  ArchivedReason: string | null;
+  IsPaused: boolean | null;
+  DocForm: string;
+  DocLanguage: string | null;
+  CreatedBy: string;
+  CreatedAt: string;
+  CompletedAt: string | null;
+  StoppedAt: string | null;
+  UpdatedAt: string;
+  SegmentCount: number;
+}
+
+export interface DocumentSegment {
+  ID: string;
+  DocumentID: string;
+  Position: number;
+  Content: string;
+  Answer: string | null;
+  WordCount: number;
+  Tokens: number;
+  Keywords: string | null;
+  HitCount: number;
+  Enabled: boolean;
+  Status: string;
+  Error: string | null;
+  CreatedAt: string;
+  CompletedAt: string | null;
+  UpdatedAt: string;
+}
+
+export interface App {
+  ID: string;
+  TenantID: string;
+  TenantName: string;
+  Name: string;
+  Description: string;
+  Mode: string;
+  Status: string;
+  EnableSite: boolean;
+  EnableAPI: boolean;
+  CreatedBy: string | null;
+  CreatedByEmail: string;
+  CreatedAt: string;
+  UpdatedAt: string;
+}
+
+export interface ApiToken {
+  ID: string;
+  AppID: string | null;
+  AppName: string;
+  TenantID: string | null;
+  TenantName: string;
+  Type: 'app' | 'dataset';
+  Token: string;
+  LastUsedAt: string | null;
+  CreatedAt: string;
+}
+
+export interface Provider {
+  ID: string;
+  TenantID: string;
+  TenantName: string;
+  ProviderName: string;
+  ProviderType: 'custom' | 'system';
+  Credentials: Record<string, unknown> | null;
+  IsValid: boolean;
+  LastUsed: string | null;
+  QuotaType: string | null;
+  QuotaLimit: number | null;
+  QuotaUsed: number | null;
+  CreatedAt: string;
+  UpdatedAt: string;
+}
+
+export interface ProviderModel {
+  ID: string;
+  TenantID: string;
+  TenantName: string;
+  ProviderName: string;
+  ModelName: string;
+  ModelType: string;
+  Credentials: Record<string, unknown> | null;
+  IsValid: boolean;
+  CreatedAt: string;
+  UpdatedAt: string;
+}
+
+export interface TenantDefaultModel {
+  ID: string;
+  TenantID: string;
+  ProviderName: string;
+  ModelName: string;
+  ModelType: string;
+  CreatedAt: string;
+  UpdatedAt: string;
+}
+
+export interface Invitation {
+  ID: string;
+  AccountID: string;
+  Email: string;
+  TenantID: string;
+  TenantName: string;
+  InvitedBy: string;
+  Status: 'pending' | 'accepted' | 'revoked' | 'expired';
+  ExpiresAt: string;
+  SentAt: string | null;
+  AcceptedAt: string | null;
+  CreatedAt: string;
+}
+
+// 密码不满足的安全策略规则，随 400 错误在 violations 中返回
+export interface PasswordViolation {
+  Rule: string;
+  Message: string;
+}
+
+export interface PasswordExpiry {
+  ID: string;
+  Email: string;
+  Name: string;
+  Status: string;
+  PasswordChangedAt: string;
+  ExpiredAt: string;
+}
+
+export interface LoginThrottle {
+  ID: string;
+  Scope: 'ip' | 'email';
+  Value: string;
+  Failures: number;
+  Locked: boolean;
+  LastFailedAt: string | null;
+  LockedUntil: string | null;
+  RetryAfter: number;
+  UpdatedAt: string;
+}
+
+export interface InvitationInfo {
+  Email: string;
+  Name: string;
+  TenantName: string;
+  ExpiresAt: string;
+}
@@ -0,0 +1,452 @@
+package handlers
+
+import (
+	"difyserver/config"
+	"difyserver/database"
+	"difyserver/models"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/mail"
+	"strings"
+	"time"
+	_ "time/tzdata" // 内置时区数据，Windows 等没有时区数据库的系统也能校验时区
+)
+
+// validTenantRoles 工作空间成员的有效角色
+var validTenantRoles = map[string]bool{
+	models.TenantRoleOwner:  true,
+	models.TenantRoleAdmin:  true,
+	models.TenantRoleEditor: true,
+	models.TenantRoleNormal: true,
+}
+
+// createAccountRequest 新建用户的请求参数，未指定的语言、时区和主题使用配置中的默认值
+type createAccountRequest struct {
+	Email             string       `json:"email"`
+	Name              string       `json:"name"`
+	Password          string       `json:"password"` // 初始密码，可为空
+	InterfaceLanguage string       `json:"interface_language"`
+	Timezone          string       `json:"timezone"`
+	InterfaceTheme    string       `json:"interface_theme"`
+	Tenants           []tenantRole `json:"tenants"` // 同时加入的工作空间
+	Pending           bool         `json:"-"`       // 通过邀请创建，用户接受邀请并设置密码后才激活
+}
+
+// tenantRole 用户在工作空间中的角色
+type tenantRole struct {
+	TenantID string `json:"tenant_id"`
+	Role     string `json:"role"`
+}
+
+// validate 校验并补全请求参数，返回错误时同时返回对应的 HTTP 状态码
+func (req *createAccountRequest) validate(c *gin.Context) (int, error) {
+	req.Email = strings.TrimSpace(req.Email)
+	req.Name = strings.TrimSpace(req.Name)
+
+	if req.Email == "" {
+		return 400, errors.New("邮箱不能为空")
+	}
+	if addr, err := mail.ParseAddress(req.Email); err != nil || addr.Address != req.Email {
+		return 400, errors.New("无效的邮箱地址")
+	}
+	if req.Name == "" {
+		req.Name = req.Email[:strings.Index(req.Email, "@")]
+	}
+	if len([]rune(req.Name)) > 255 {
+		return 400, errors.New("用户名不能超过255个字符")
+	}
+	if req.Password != "" {
+		if err := checkPassword(database.DB, req.Password, nil); err != nil {
+			var policyErr *passwordPolicyError
+			if errors.As(err, &policyErr) {
+				return 400, err
+			}
+			return 500, err
+		}
+	}
+
+	defaults := config.GlobalConfig.AccountDefaults
+	if req.InterfaceLanguage == "" {
+		req.InterfaceLanguage = defaults.InterfaceLanguage
+	}
+	if req.Timezone == "" {
+		req.Timezone = defaults.Timezone
+	}
+	if req.InterfaceTheme == "" {
+		req.InterfaceTheme = defaults.InterfaceTheme
+	}
+	if !models.ValidInterfaceLanguages[req.InterfaceLanguage] {
+		return 400, errors.New("无效的界面语言")
+	}
+	if !validTimezone(req.Timezone) {
+		return 400, errors.New("无效的时区")
+	}
+	if !models.ValidInterfaceThemes[req.InterfaceTheme] {
+		return 400, errors.New("无效的界面主题")
+	}
+
+	// 受限管理员创建的用户必须加入其管理的工作空间，否则创建后无法再管理
+	_, restricted, err := tenantScope(c)
+	if err != nil {
+		return 500, err
+	}
+	if restricted && len(req.Tenants) == 0 {
+		return 400, errors.New("请选择用户要加入的工作空间")
+	}
+
+	seen := map[string]bool{}
+	for i := range req.Tenants {
+		tenant := &req.Tenants[i]
+		if tenant.TenantID == "" {
+			return 400, errors.New("tenant_id 不能为空")
+		}
+		if seen[tenant.TenantID] {
+			return 400, fmt.Errorf("工作空间 %s 重复", tenant.TenantID)
+		}
+		seen[tenant.TenantID] = true
+
+		if tenant.Role == "" {
+			tenant.Role = models.TenantRoleNormal // 默认角色
+		} else if !validTenantRoles[tenant.Role] {
+			return 400, fmt.Errorf("无效的角色值: %s", tenant.Role)
+		}
+
+		ok, err := tenantInScope(c, tenant.TenantID)
+		if err != nil {
+			return 500, err
+		}
+		if !ok {
+			return 403, fmt.Errorf("没有权限管理工作空间 %s", tenant.TenantID)
+		}
+
+		var count int64
+		if err := database.DB.Model(&models.Tenant{}).Where("id = ?", tenant.TenantID).Count(&count).Error; err != nil {
+			return 500, err
+		}
+		if count == 0 {
+			return 400, fmt.Errorf("工作空间 %s 不存在", tenant.TenantID)
+		}
+
+		// 每个工作空间只能有一个所有者
+		if tenant.Role == models.TenantRoleOwner {
+			hasOwner, err := tenantHasOwner(database.DB, tenant.TenantID, "")
+			if err != nil {
+				return 500, err
+			}
+			if hasOwner {
+				return 400, fmt.Errorf("工作空间 %s 已有所有者", tenant.TenantID)
+			}
+		}
+	}
+
+	// 邮箱不区分大小写，已存在时返回 409
+	var count int64
+	if err := database.DB.Model(&models.Account{}).Where("LOWER(email) = LOWER(?)", req.Email).Count(&count).Error; err != nil {
+		return 500, err
+	}
+	if count > 0 {
+		return 409, errors.New("该邮箱已被注册")
+	}
+
+	return 200, nil
+}
+
+// create 在事务中创建用户及其工作空间关联，需先调用 validate
+func (req *createAccountRequest) create(tx *gorm.DB) (models.Account, []models.TenantAccountJoin, error) {
+	now := time.Now()
+	account := models.Account{
+		ID:                uuid.New().String(),
+		Name:              req.Name,
+		Email:             req.Email,
+		Avatar:            config.GlobalConfig.AccountDefaults.Avatar,
+		InterfaceLanguage: req.InterfaceLanguage,
+		InterfaceTheme:    req.InterfaceTheme,
+		Timezone:          req.Timezone,
+		Status:            models.AccountStatusActive,
+		InitializedAt:     &now,
+	}
+
+	// 邀请创建或未设置密码的用户无法登录，状态为 pending，设置密码后才能激活
+	if req.Pending || req.Password == "" {
+		account.Status = models.AccountStatusPending
+		account.InitializedAt = nil
+	}
+
+	if req.Password != "" {
+		password, salt, err := encodePassword(req.Password)
+		if err != nil {
+			return account, nil, err
+		}
+		account.Password = password
+		account.PasswordSalt = salt
+	}
+
+	if err := tx.Create(&account).Error; err != nil {
+		return account, nil, err
+	}
+	if account.Password != "" {
+		if err := recordPasswordHistory(tx, account.ID, account.Password, account.PasswordSalt); err != nil {
+			return account, nil, err
+		}
+	}
+
+	joins := make([]models.TenantAccountJoin, 0, len(req.Tenants))
+	for i, tenant := range req.Tenants {
+		// 批量导入时多个新用户可能被设为同一工作空间的所有者，在事务中再检查一次
+		if tenant.Role == models.TenantRoleOwner {
+			hasOwner, err := tenantHasOwner(tx, tenant.TenantID, "")
+			if err != nil {
+				return account, nil, err
+			}
+			if hasOwner {
+				return account, nil, fmt.Errorf("工作空间 %s 已有所有者", tenant.TenantID)
+			}
+		}
+
+		joins = append(joins, models.TenantAccountJoin{
+			ID:        uuid.New().String(),
+			TenantID:  tenant.TenantID,
+			AccountID: account.ID,
+			Role:      tenant.Role,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Current:   i == 0, // 第一个工作空间作为当前工作空间
+		})
+	}
+	if len(joins) > 0 {
+		if err := tx.Create(&joins).Error; err != nil {
+			return account, nil, err
+		}
+	}
+
+	return account, joins, nil
+}
+
+func UpdateAccount(c *gin.Context) {
+	var req struct {
+		ID                string  `json:"id"`
+		Name              *string `json:"name"`
+		InterfaceLanguage *string `json:"interface_language"`
+		InterfaceTheme    *string `json:"interface_theme"`
+		Timezone          *string `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" {
+		c.JSON(400, gin.H{"error": "用户ID不能为空"})
+		return
+	}
+
+	// 只更新传入的字段
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len([]rune(name)) > 255 {
+			c.JSON(400, gin.H{"error": "用户名不能为空且不能超过255个字符"})
+			return
+		}
+		updates["name"] = name
+	}
+	if req.InterfaceLanguage != nil {
+		if !models.ValidInterfaceLanguages[*req.InterfaceLanguage] {
+			c.JSON(400, gin.H{"error": "无效的界面语言"})
+			return
+		}
+		updates["interface_language"] = *req.InterfaceLanguage
+	}
+	if req.InterfaceTheme != nil {
+		if !models.ValidInterfaceThemes[*req.InterfaceTheme] {
+			c.JSON(400, gin.H{"error": "无效的界面主题"})
+			return
+		}
+		updates["interface_theme"] = *req.InterfaceTheme
+	}
+	if req.Timezone != nil {
+		if !validTimezone(*req.Timezone) {
+			c.JSON(400, gin.H{"error": "无效的时区"})
+			return
+		}
+		updates["timezone"] = *req.Timezone
+	}
+
+	if len(updates) == 0 {
+		c.JSON(400, gin.H{"error": "没有需要更新的字段"})
+		return
+	}
+
+	if !requireAccountInScope(c, req.ID) {
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var account models.Account
+	if err := tx.Where("id = ?", req.ID).First(&account).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定用户"})
+		return
+	}
+
+	before := gin.H{
+		"name":               account.Name,
+		"interface_language": account.InterfaceLanguage,
+		"interface_theme":    account.InterfaceTheme,
+		"timezone":           account.Timezone,
+	}
+
+	if err := tx.Model(&account).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "更新用户失败"})
+		return
+	}
+
+	if err := writeAuditLog(c, tx, AuditUpdateAccount, AuditTargetAccount, account.ID, "", before, updates); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": "更新用户失败"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "更新用户成功"})
+}
+
+// SetAccountStatus 设置用户状态，banned 和 closed 的用户无法登录 Dify，用于停用离职人员而不删除数据
+func SetAccountStatus(c *gin.Context) {
+	var req struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" || req.Status == "" {
+		c.JSON(400, gin.H{"error": "用户ID和状态不能为空"})
+		return
+	}
+
+	if !models.ValidAccountStatuses[req.Status] {
+		c.JSON(400, gin.H{"error": "无效的用户状态"})
+		return
+	}
+
+	if req.ID == c.GetString("userID") && req.Status != models.AccountStatusActive {
+		c.JSON(400, gin.H{"error": "不能停用自己的账号"})
+		return
+	}
+
+	if !requireAccountInScope(c, req.ID) {
+		return
+	}
+
+	if !changeAccountStatus(c, req.ID, req.Status, AuditSetAccountStatus) {
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "设置用户状态成功"})
+}
+
+// ActivateAccount 重新启用用户，未完成初始化的用户同时记录初始化时间
+func ActivateAccount(c *gin.Context) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" {
+		c.JSON(400, gin.H{"error": "用户ID不能为空"})
+		return
+	}
+
+	if !requireAccountInScope(c, req.ID) {
+		return
+	}
+
+	if !changeAccountStatus(c, req.ID, models.AccountStatusActive, AuditActivateAccount) {
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "启用用户成功"})
+}
+
+// changeAccountStatus 修改用户状态并写入审计日志，失败时直接返回错误响应
+func changeAccountStatus(c *gin.Context, accountID, status, action string) bool {
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var account models.Account
+	if err := tx.Where("id = ?", accountID).First(&account).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定用户"})
+		return false
+	}
+
+	// 没有密码的用户启用后也无法登录，需先设置密码或通过邀请链接激活
+	if status == models.AccountStatusActive && account.Password == "" {
+		tx.Rollback()
+		c.JSON(400, gin.H{"error": "用户未设置密码，请先设置密码或发送邀请"})
+		return false
+	}
+
+	updates := map[string]interface{}{
+		"status": status,
+	}
+	if status == models.AccountStatusActive && account.InitializedAt == nil {
+		updates["initialized_at"] = time.Now()
+	}
+	if err := tx.Model(&account).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "设置用户状态失败"})
+		return false
+	}
+
+	// 停用的用户已登录的管理后台会话同时失效
+	if status == models.AccountStatusBanned || status == models.AccountStatusClosed {
+		if err := revokeAccountTokens(tx, account.ID, "status_"+status); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return false
+		}
+	}
+
+	before := gin.H{"status": account.Status}
+	after := gin.H{"status": status}
+	if err := writeAuditLog(c, tx, action, AuditTargetAccount, account.ID, "", before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return false
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": "设置用户状态失败"})
+		return false
+	}
+
+	return true
+}
+
+// validTimezone 校验是否为有效的 IANA 时区名称，例如 Asia/Shanghai
+func validTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
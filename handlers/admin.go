@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"time"
+)
+
+func GetAdminRoles(c *gin.Context) {
+	var roles []models.AdminRole
+	var total int64
+
+	query, params, err := parseListQuery(c, database.DB.Model(&models.AdminRole{}), adminRoleListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&roles)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(200, params.response(roles, total))
+}
+
+func SetAdminRole(c *gin.Context) {
+	var req struct {
+		AccountID string `json:"account_id"`
+		Role      string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AccountID == "" || req.Role == "" {
+		c.JSON(400, gin.H{"error": "account_id 和 role 不能为空"})
+		return
+	}
+
+	if !models.ValidAdminRoles[req.Role] {
+		c.JSON(400, gin.H{"error": "无效的管理员角色"})
+		return
+	}
+
+	// 不允许修改自己的角色，避免误操作导致没有超级管理员
+	if req.AccountID == c.GetString("userID") {
+		c.JSON(400, gin.H{"error": "不能修改自己的管理员角色"})
+		return
+	}
+
+	var account models.Account
+	if err := database.DB.Where("id = ?", req.AccountID).First(&account).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定用户"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var adminRole models.AdminRole
+	var before interface{}
+	err := tx.Where("account_id = ?", req.AccountID).First(&adminRole).Error
+	if err == nil {
+		// 已存在则更新角色
+		before = gin.H{"role": adminRole.Role}
+		adminRole.Role = req.Role
+		adminRole.Email = account.Email
+		adminRole.UpdatedAt = time.Now()
+		err = tx.Save(&adminRole).Error
+	} else {
+		adminRole = models.AdminRole{
+			ID:        uuid.New().String(),
+			AccountID: account.ID,
+			Email:     account.Email,
+			Role:      req.Role,
+			CreatedBy: c.GetString("userID"),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		err = tx.Create(&adminRole).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	after := gin.H{"role": adminRole.Role, "email": adminRole.Email}
+	if err := writeAuditLog(c, tx, AuditSetAdminRole, AuditTargetAccount, account.ID, "", before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, adminRole)
+}
+
+func DelAdminRole(c *gin.Context) {
+	var req struct {
+		AccountID string `json:"account_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AccountID == "" {
+		c.JSON(400, gin.H{"error": "account_id 不能为空"})
+		return
+	}
+
+	if req.AccountID == c.GetString("userID") {
+		c.JSON(400, gin.H{"error": "不能移除自己的管理员角色"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var adminRole models.AdminRole
+	if err := tx.Where("account_id = ?", req.AccountID).First(&adminRole).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "该用户不是管理员"})
+		return
+	}
+
+	if err := tx.Delete(&adminRole).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := gin.H{"role": adminRole.Role, "email": adminRole.Email}
+	if err := writeAuditLog(c, tx, AuditDelAdminRole, AuditTargetAccount, adminRole.AccountID, "", before, nil); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "移除管理员成功"})
+}
+
+func GetAdminTenants(c *gin.Context) {
+	accountID := c.Query("account_id")
+	if accountID == "" {
+		c.JSON(400, gin.H{"error": "account_id 参数必填"})
+		return
+	}
+
+	var bindings []models.AdminTenant
+	if err := database.DB.Where("account_id = ?", accountID).Order("created_at").Find(&bindings).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"data": bindings})
+}
+
+// SetAdminTenants 设置管理员可管理的工作空间，会覆盖原有的绑定关系
+func SetAdminTenants(c *gin.Context) {
+	var req struct {
+		AccountID string   `json:"account_id"`
+		TenantIDs []string `json:"tenant_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AccountID == "" {
+		c.JSON(400, gin.H{"error": "account_id 不能为空"})
+		return
+	}
+
+	var account models.Account
+	if err := database.DB.Where("id = ?", req.AccountID).First(&account).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定用户"})
+		return
+	}
+
+	// 验证工作空间是否存在，重复的ID只绑定一次
+	req.TenantIDs = uniqueStrings(req.TenantIDs)
+	if len(req.TenantIDs) > 0 {
+		var count int64
+		if err := database.DB.Model(&models.Tenant{}).Where("id IN ?", req.TenantIDs).Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if int(count) != len(req.TenantIDs) {
+			c.JSON(400, gin.H{"error": "存在无效的工作空间ID"})
+			return
+		}
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var previous []string
+	if err := tx.Model(&models.AdminTenant{}).Where("account_id = ?", req.AccountID).Pluck("tenant_id", &previous).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Where("account_id = ?", req.AccountID).Delete(&models.AdminTenant{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "清除原有绑定失败"})
+		return
+	}
+
+	bindings := make([]models.AdminTenant, 0, len(req.TenantIDs))
+	for _, tenantID := range req.TenantIDs {
+		bindings = append(bindings, models.AdminTenant{
+			ID:        uuid.New().String(),
+			AccountID: req.AccountID,
+			TenantID:  tenantID,
+			CreatedBy: c.GetString("userID"),
+			CreatedAt: time.Now(),
+		})
+	}
+	if len(bindings) > 0 {
+		if err := tx.Create(&bindings).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "保存绑定失败"})
+			return
+		}
+	}
+
+	before := gin.H{"tenant_ids": previous}
+	after := gin.H{"tenant_ids": req.TenantIDs}
+	if err := writeAuditLog(c, tx, AuditSetAdminTenants, AuditTargetAccount, req.AccountID, "", before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": "保存绑定失败"})
+		return
+	}
+
+	c.JSON(200, gin.H{"data": bindings})
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"difyserver/database"
+	"difyserver/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	apiTokenLength     = 24 // 前缀之后的随机字符数，与 Dify 一致
+	maxAPITokensPerApp = 10 // 每个应用或工作空间最多的密钥数，与 Dify 一致
+	apiTokenCharset    = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// apiTokenPrefixes 各类型密钥的前缀
+var apiTokenPrefixes = map[string]string{
+	models.APITokenTypeApp:     "app-",
+	models.APITokenTypeDataset: "dataset-",
+}
+
+// GetAPITokens 跨工作空间查看 API 密钥，密钥内容脱敏
+// 除通用列表参数外支持：
+// token 按完整的密钥精确查找，用于定位泄露的密钥；
+// last_used_before 只返回在该时间之后没有使用过的密钥；
+// app_created_by 只返回该用户创建的应用的密钥；
+// created_by 只返回该用户通过 DifyServer 创建的密钥（Dify 本身不记录密钥的创建人）
+func GetAPITokens(c *gin.Context) {
+	var tokens []models.APIToken
+	var total int64
+
+	query, err := scopeByTenant(c, database.DB.Model(&models.APIToken{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if token := strings.TrimSpace(c.Query("token")); token != "" {
+		query = query.Where("token = ?", token)
+	}
+	if before := c.Query("last_used_before"); before != "" {
+		t, err := parseQueryTime(before)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "last_used_before 时间格式错误"})
+			return
+		}
+		query = query.Where("last_used_at IS NULL OR last_used_at < ?", t)
+	}
+	if accountID := c.Query("app_created_by"); accountID != "" {
+		query = query.Where("app_id IN (SELECT id FROM apps WHERE created_by = ?)", accountID)
+	}
+	if accountID := c.Query("created_by"); accountID != "" {
+		query = query.Where("id::text IN (SELECT target_id FROM difyserver_audit_logs WHERE action = ? AND actor_id = ?)",
+			AuditAddAPIToken, accountID)
+	}
+
+	query, params, err := parseListQuery(c, query, apiTokenListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&tokens)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	data, err := apiTokenResponses(tokens)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, params.response(data, total))
+}
+
+// AddAPIToken 创建 API 密钥，应用密钥需要 app_id，知识库密钥需要 tenant_id
+// 完整的密钥只在创建时返回一次
+func AddAPIToken(c *gin.Context) {
+	var req struct {
+		Type     string `json:"type"`
+		AppID    string `json:"app_id"`
+		TenantID string `json:"tenant_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefix, ok := apiTokenPrefixes[req.Type]
+	if !ok {
+		c.JSON(400, gin.H{"error": "type 只能是 app 或 dataset"})
+		return
+	}
+
+	token := models.APIToken{
+		ID:        uuid.New().String(),
+		Type:      req.Type,
+		CreatedAt: time.Now(),
+	}
+	if req.Type == models.APITokenTypeApp {
+		if req.AppID == "" {
+			c.JSON(400, gin.H{"error": "app_id 不能为空"})
+			return
+		}
+		app, ok := requireAppInScope(c, req.AppID)
+		if !ok {
+			return
+		}
+		token.AppID = &app.ID
+		token.TenantID = &app.TenantID
+	} else {
+		if req.TenantID == "" {
+			c.JSON(400, gin.H{"error": "tenant_id 不能为空"})
+			return
+		}
+		if !requireTenantInScope(c, req.TenantID) {
+			return
+		}
+		var count int64
+		if err := database.DB.Model(&models.Tenant{}).Where("id = ?", req.TenantID).Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if count == 0 {
+			c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+			return
+		}
+		token.TenantID = &req.TenantID
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	count := tx.Model(&models.APIToken{}).Where("type = ?", token.Type)
+	if token.AppID != nil {
+		count = count.Where("app_id = ?", *token.AppID)
+	} else {
+		count = count.Where("tenant_id = ?", *token.TenantID)
+	}
+	var existing int64
+	if err := count.Count(&existing).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if existing >= maxAPITokensPerApp {
+		tx.Rollback()
+		c.JSON(400, gin.H{"error": "API 密钥数量已达上限"})
+		return
+	}
+
+	// 与 Dify 一样生成不重复的密钥
+	for {
+		random, err := randomString(apiTokenLength)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		token.Token = prefix + random
+
+		var duplicate int64
+		if err := tx.Model(&models.APIToken{}).Where("token = ?", token.Token).Count(&duplicate).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if duplicate == 0 {
+			break
+		}
+	}
+
+	if err := tx.Create(&token).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID := ""
+	if token.TenantID != nil {
+		tenantID = *token.TenantID
+	}
+	after := gin.H{"type": token.Type, "app_id": token.AppID, "token": models.MaskAPIToken(token.Token)}
+	if err := writeAuditLog(c, tx, AuditAddAPIToken, AuditTargetAPIToken, token.ID, tenantID, nil, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 只有创建时返回完整的密钥，之后只能看到脱敏后的值
+	response := models.NewAPITokenResponse(token)
+	response.Token = token.Token
+	c.JSON(200, response)
+}
+
+// RevokeAPITokens 批量删除 API 密钥，任何一个密钥不存在或不在管理范围内时都不删除
+func RevokeAPITokens(c *gin.Context) {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		c.JSON(400, gin.H{"error": "ids 不能为空"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var tokens []models.APIToken
+	if err := tx.Where("id IN ?", req.IDs).Find(&tokens).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	found := map[string]bool{}
+	for _, token := range tokens {
+		found[token.ID] = true
+	}
+	var missing []string
+	for _, id := range req.IDs {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "以下密钥不存在", "ids": missing})
+		return
+	}
+
+	for _, token := range tokens {
+		tenantID := ""
+		if token.TenantID != nil {
+			tenantID = *token.TenantID
+		}
+		ok, err := tenantInScope(c, tenantID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if !ok {
+			tx.Rollback()
+			c.JSON(403, gin.H{"error": "没有权限管理密钥 " + token.ID})
+			return
+		}
+
+		before := gin.H{"type": token.Type, "app_id": token.AppID, "token": models.MaskAPIToken(token.Token), "last_used_at": token.LastUsedAt}
+		if err := writeAuditLog(c, tx, AuditRevokeAPIToken, AuditTargetAPIToken, token.ID, tenantID, before, nil); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "写入审计日志失败"})
+			return
+		}
+	}
+
+	if err := tx.Where("id IN ?", req.IDs).Delete(&models.APIToken{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "删除密钥失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "删除密钥成功", "count": len(tokens)})
+}
+
+// randomString 生成由字母和数字组成的随机字符串
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(apiTokenCharset)))
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = apiTokenCharset[idx.Int64()]
+	}
+	return string(b), nil
+}
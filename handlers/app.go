@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"time"
+)
+
+// appTenantTables 带 tenant_id 和 app_id 字段、迁移应用时需要同步修改 tenant_id 的表
+// 部分表只在较新的 Dify 版本中存在，不存在时跳过
+var appTenantTables = []string{
+	"workflows",
+	"workflow_runs",
+	"workflow_node_executions",
+	"workflow_app_logs",
+	"api_tokens",
+	"end_users",
+	"workflow_tool_providers",
+	"app_mcp_servers",
+}
+
+func GetApps(c *gin.Context) {
+	var apps []models.App
+	var total int64
+
+	query, err := scopeByTenant(c, database.DB.Model(&models.App{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, appListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&apps)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	data, err := appResponses(apps)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, params.response(data, total))
+}
+
+// SetAppAccess 开启或关闭应用的公开访问站点和 API
+func SetAppAccess(c *gin.Context) {
+	var req struct {
+		AppID      string `json:"app_id"`
+		EnableSite *bool  `json:"enable_site"`
+		EnableAPI  *bool  `json:"enable_api"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AppID == "" {
+		c.JSON(400, gin.H{"error": "app_id 不能为空"})
+		return
+	}
+	if req.EnableSite == nil && req.EnableAPI == nil {
+		c.JSON(400, gin.H{"error": "请指定 enable_site 或 enable_api"})
+		return
+	}
+
+	app, ok := requireAppInScope(c, req.AppID)
+	if !ok {
+		return
+	}
+
+	before := gin.H{}
+	after := gin.H{}
+	updates := map[string]interface{}{"updated_at": time.Now(), "updated_by": c.GetString("userID")}
+	if req.EnableSite != nil {
+		before["enable_site"] = app.EnableSite
+		after["enable_site"] = *req.EnableSite
+		updates["enable_site"] = *req.EnableSite
+	}
+	if req.EnableAPI != nil {
+		before["enable_api"] = app.EnableAPI
+		after["enable_api"] = *req.EnableAPI
+		updates["enable_api"] = *req.EnableAPI
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&models.App{}).Where("id = ?", app.ID).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := writeAuditLog(c, tx, AuditSetAppAccess, AuditTargetApp, app.ID, app.TenantID, before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "修改应用访问设置成功"})
+}
+
+// MoveApp 把应用及其工作流、运行记录、API 密钥等关联数据迁移到另一个工作空间
+// 应用引用的其他工作空间的知识库和标签会被移除，工作流节点中引用的模型和知识库需要在目标工作空间中重新配置
+func MoveApp(c *gin.Context) {
+	var req struct {
+		AppID    string `json:"app_id"`
+		TenantID string `json:"tenant_id"`
+		DryRun   bool   `json:"dry_run"` // 只统计会修改的数据，不提交
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AppID == "" || req.TenantID == "" {
+		c.JSON(400, gin.H{"error": "app_id 和 tenant_id 不能为空"})
+		return
+	}
+
+	// 目标工作空间和应用当前所属工作空间都需要在管理范围内
+	if !requireTenantInScope(c, req.TenantID) {
+		return
+	}
+	app, ok := requireAppInScope(c, req.AppID)
+	if !ok {
+		return
+	}
+	if app.TenantID == req.TenantID {
+		c.JSON(400, gin.H{"error": "应用已属于该工作空间"})
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Tenant{}).Where("id = ?", req.TenantID).Count(&count).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return
+	}
+
+	report := &models.AppMoveReport{
+		AppID:        app.ID,
+		FromTenantID: app.TenantID,
+		ToTenantID:   req.TenantID,
+		DryRun:       req.DryRun,
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if err := moveAppRows(tx, app, req.TenantID, report); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 只校验时回滚，返回的统计与实际执行时一致
+	if req.DryRun {
+		tx.Rollback()
+		c.JSON(200, gin.H{"message": "校验通过", "report": report})
+		return
+	}
+
+	// 原工作空间和目标工作空间各记录一条，两边的工作空间管理员都能看到应用的迁移
+	before := gin.H{"tenant_id": app.TenantID}
+	after := gin.H{"tenant_id": req.TenantID, "changes": report.Changes}
+	for _, tenantID := range []string{req.TenantID, app.TenantID} {
+		if err := writeAuditLog(c, tx, AuditMoveApp, AuditTargetApp, app.ID, tenantID, before, after); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "写入审计日志失败"})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "迁移应用成功", "report": report})
+}
+
+// moveAppRows 修改应用及依赖表中的数据，并把每张表的修改行数记录到 report
+func moveAppRows(tx *gorm.DB, app models.App, tenantID string, report *models.AppMoveReport) error {
+	m := newTableMover(tx)
+	defer func() {
+		report.Changes = m.changes
+		report.SkippedTables = m.skipped
+	}()
+
+	if err := m.exec("apps", "update", "", "UPDATE apps SET tenant_id = ? WHERE id = ?", tenantID, app.ID); err != nil {
+		return err
+	}
+
+	for _, table := range appTenantTables {
+		if !m.hasTable(table) {
+			continue
+		}
+		if err := m.exec(table, "update", "",
+			"UPDATE "+table+" SET tenant_id = ? WHERE app_id = ? AND tenant_id IS DISTINCT FROM ?",
+			tenantID, app.ID, tenantID); err != nil {
+			return err
+		}
+	}
+
+	// 原工作空间中安装的应用改为安装到目标工作空间
+	// (tenant_id, app_id) 唯一，目标工作空间已安装时删除原工作空间中的安装记录
+	if m.hasTable("installed_apps") {
+		if err := m.exec("installed_apps", "delete", "目标工作空间已安装，删除原工作空间中的安装记录",
+			`DELETE FROM installed_apps WHERE app_id = ? AND tenant_id = ? AND EXISTS (
+			SELECT 1 FROM installed_apps t WHERE t.app_id = ? AND t.tenant_id = ?)`,
+			app.ID, app.TenantID, app.ID, tenantID); err != nil {
+			return err
+		}
+		if err := m.exec("installed_apps", "update", "原工作空间中的安装记录",
+			"UPDATE installed_apps SET tenant_id = ? WHERE app_id = ? AND tenant_id = ?",
+			tenantID, app.ID, app.TenantID); err != nil {
+			return err
+		}
+		if err := m.exec("installed_apps", "update", "app_owner_tenant_id",
+			"UPDATE installed_apps SET app_owner_tenant_id = ? WHERE app_id = ?",
+			tenantID, app.ID); err != nil {
+			return err
+		}
+	}
+
+	// 应用不能引用其他工作空间的知识库
+	if m.hasTable("app_dataset_joins") {
+		if err := m.exec("app_dataset_joins", "delete", "不属于目标工作空间的知识库",
+			`DELETE FROM app_dataset_joins WHERE app_id = ? AND dataset_id NOT IN (
+			SELECT id FROM datasets WHERE tenant_id = ?)`, app.ID, tenantID); err != nil {
+			return err
+		}
+	}
+
+	// 标签属于原工作空间
+	if m.hasTable("tag_bindings") {
+		if err := m.exec("tag_bindings", "delete", "原工作空间的标签",
+			"DELETE FROM tag_bindings WHERE target_id = ? AND tenant_id = ?", app.ID, app.TenantID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// requireAppInScope 查询应用并检查其所属工作空间是否在管理范围内，失败时直接返回错误响应
+func requireAppInScope(c *gin.Context, appID string) (models.App, bool) {
+	var app models.App
+	if err := database.DB.Where("id = ?", appID).First(&app).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定应用"})
+		return app, false
+	}
+	if !requireTenantInScope(c, app.TenantID) {
+		return app, false
+	}
+	return app, true
+}
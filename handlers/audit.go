@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// 审计日志操作类型
+const (
+	AuditAddAccount              = "add_account"
+	AuditDelAccount              = "del_account"
+	AuditSetAccountPassword      = "set_account_password"
+	AuditChangePassword          = "change_password"
+	AuditLoginFailed             = "login_failed"
+	AuditClearLoginThrottle      = "clear_login_throttle"
+	AuditUpdateAccount           = "update_account"
+	AuditSetAccountStatus        = "set_account_status"
+	AuditActivateAccount         = "activate_account"
+	AuditInviteAccount           = "invite_account"
+	AuditResendInvitation        = "resend_invitation"
+	AuditRevokeInvitation        = "revoke_invitation"
+	AuditAcceptInvitation        = "accept_invitation"
+	AuditAddTenant               = "add_tenant"
+	AuditUpdateTenant            = "update_tenant"
+	AuditSetTenantPlan           = "set_tenant_plan"
+	AuditSetTenantStatus         = "set_tenant_status"
+	AuditDelTenant               = "del_tenant"
+	AuditCloneTenant             = "clone_tenant"
+	AuditTransferTenantOwner     = "transfer_tenant_owner"
+	AuditAddTenantAccount        = "add_tenant_account"
+	AuditDelTenantAccount        = "del_tenant_account"
+	AuditUpdateTenantAccountRole = "update_tenant_account_role"
+	AuditAddDatasetTenant        = "add_dataset_tenant"
+	AuditMoveDataset             = "move_dataset"
+	AuditSetDatasetPermission    = "set_dataset_permission"
+	AuditDelDatasetTenant        = "del_dataset_tenant"
+	AuditMoveApp                 = "move_app"
+	AuditSetAppAccess            = "set_app_access"
+	AuditAddAPIToken             = "add_api_token"
+	AuditRevokeAPIToken          = "revoke_api_token"
+	AuditSetTenantDefaultModel   = "set_tenant_default_model"
+	AuditCopyProvider            = "copy_provider"
+	AuditSetAdminRole            = "set_admin_role"
+	AuditDelAdminRole            = "del_admin_role"
+	AuditSetAdminTenants         = "set_admin_tenants"
+	AuditRevokeAccountTokens     = "revoke_account_tokens"
+)
+
+// 审计日志对象类型
+const (
+	AuditTargetAccount       = "account"
+	AuditTargetTenant        = "tenant"
+	AuditTargetTenantAccount = "tenant_account"
+	AuditTargetDataset       = "dataset"
+	AuditTargetApp           = "app"
+	AuditTargetAPIToken      = "api_token"
+	AuditTargetProvider      = "provider"
+	AuditTargetLoginThrottle = "login_throttle"
+)
+
+// writeAuditLog 在指定事务中写入一条审计日志，before 和 after 会被序列化为 JSON
+func writeAuditLog(c *gin.Context, tx *gorm.DB, action, targetType, targetID, tenantID string, before, after interface{}) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	log := models.AuditLog{
+		ID:         uuid.New().String(),
+		ActorID:    c.GetString("userID"),
+		ActorEmail: c.GetString("userEmail"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		TenantID:   tenantID,
+		Before:     beforeJSON,
+		After:      afterJSON,
+		ClientIP:   c.ClientIP(),
+		CreatedAt:  time.Now(),
+	}
+	return tx.Create(&log).Error
+}
+
+func auditJSON(v interface{}) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func GetAuditLogs(c *gin.Context) {
+	var logs []models.AuditLog
+	var total int64
+
+	// 受限管理员只能查看其管理的工作空间的日志
+	query, err := scopeByTenant(c, database.DB.Model(&models.AuditLog{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 操作人可以填写ID或邮箱
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("(actor_id = ? OR actor_email = ?)", actor, actor)
+	}
+
+	query, params, err := parseListQuery(c, query, auditLogListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&logs)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(200, params.response(logs, total))
+}
+
+// parseQueryTime 解析查询参数中的时间，支持 RFC3339 和 2006-01-02 两种格式
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// parseQueryEndTime 解析结束时间，只传日期时包含当天
+func parseQueryEndTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return t, err
+	}
+	return t.Add(24*time.Hour - time.Microsecond), nil
+}
@@ -0,0 +1,593 @@
+package handlers
+
+import (
+	"crypto/rsa"
+	"difyserver/database"
+	"difyserver/models"
+	"difyserver/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const siteCodeLength = 16 // 应用公开访问地址的随机码长度，与 Dify 一致
+
+// cloneTenantRequest 复制工作空间的参数，成员、应用和知识库都必须属于源工作空间
+type cloneTenantRequest struct {
+	SourceTenantID string   `json:"source_tenant_id"`
+	Name           string   `json:"name"`
+	OwnerID        string   `json:"owner_id"` // 新工作空间的所有者，默认为当前管理员
+	MemberIDs      []string `json:"member_ids"`
+	AppIDs         []string `json:"app_ids"`
+	DatasetIDs     []string `json:"dataset_ids"`
+}
+
+// tenantCloner 在事务中复制工作空间的内容，记录旧 ID 到新 ID 的对应关系
+type tenantCloner struct {
+	tx         *gorm.DB
+	tenantID   string
+	ownerID    string
+	members    map[string]bool // 新工作空间的成员
+	privateKey *rsa.PrivateKey // 源工作空间私钥，读取失败时为 nil
+	publicKey  *rsa.PublicKey  // 新工作空间公钥
+	datasetIDs map[string]string
+	now        time.Time
+	report     *models.TenantCloneReport
+}
+
+// CloneTenant 以已有工作空间为模板创建新工作空间
+// 复制计划、自定义配置、模型供应商凭据和默认模型，以及选中的成员（保留角色）、应用和知识库
+// 知识库只复制设置，不复制文档；应用复制配置、工作流和公开站点，不复制对话记录、API 密钥和标注
+func CloneTenant(c *gin.Context) {
+	var req cloneTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.SourceTenantID == "" || req.Name == "" {
+		c.JSON(400, gin.H{"error": "source_tenant_id 和 name 不能为空"})
+		return
+	}
+	if req.OwnerID == "" {
+		req.OwnerID = c.GetString("userID")
+	}
+
+	// 受限管理员不能创建新的工作空间
+	if _, restricted, err := tenantScope(c); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	} else if restricted {
+		c.JSON(403, gin.H{"error": "没有权限创建工作空间"})
+		return
+	}
+
+	var source models.Tenant
+	if err := database.DB.Where("id = ?", req.SourceTenantID).First(&source).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到源工作空间"})
+		return
+	}
+
+	var owner models.Account
+	if err := database.DB.Where("id = ?", req.OwnerID).First(&owner).Error; err != nil {
+		c.JSON(400, gin.H{"error": "所有者用户不存在"})
+		return
+	}
+
+	var joins []models.TenantAccountJoin
+	if len(req.MemberIDs) > 0 {
+		if err := database.DB.Where("tenant_id = ? AND account_id IN ?", source.ID, req.MemberIDs).
+			Find(&joins).Error; err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		members := map[string]bool{}
+		for _, join := range joins {
+			members[join.AccountID] = true
+		}
+		if len(members) != len(uniqueStrings(req.MemberIDs)) {
+			c.JSON(400, gin.H{"error": "部分用户不是源工作空间的成员"})
+			return
+		}
+	}
+
+	var apps []models.App
+	if len(req.AppIDs) > 0 {
+		if err := database.DB.Where("tenant_id = ? AND id IN ?", source.ID, req.AppIDs).Find(&apps).Error; err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if len(apps) != len(uniqueStrings(req.AppIDs)) {
+			c.JSON(400, gin.H{"error": "部分应用不属于源工作空间"})
+			return
+		}
+	}
+
+	var datasets []models.Dataset
+	if len(req.DatasetIDs) > 0 {
+		if err := database.DB.Where("tenant_id = ? AND id IN ?", source.ID, req.DatasetIDs).Find(&datasets).Error; err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if len(datasets) != len(uniqueStrings(req.DatasetIDs)) {
+			c.JSON(400, gin.H{"error": "部分知识库不属于源工作空间"})
+			return
+		}
+		for _, dataset := range datasets {
+			if dataset.Provider == "external" {
+				c.JSON(400, gin.H{"error": "外部知识库不支持复制: " + dataset.Name})
+				return
+			}
+		}
+	}
+
+	config, err := loadProviderConfig(database.DB, source.ID, nil)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	var defaults []models.TenantDefaultModel
+	if err := database.DB.Where("tenant_id = ?", source.ID).Find(&defaults).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 没有需要解密的凭据时允许源工作空间没有私钥
+	privateKey, err := utils.LoadPrivateKey(source.ID)
+	if err != nil && len(config.Providers)+len(config.Models) > 0 {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	tenant := models.Tenant{
+		ID:           uuid.New().String(),
+		Name:         req.Name,
+		Plan:         source.Plan,
+		Status:       models.TenantStatusNormal,
+		CustomConfig: source.CustomConfig,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	// 生成工作空间的密钥对，私钥保存到与 Dify 共用的存储中
+	publicKeyPEM, err := utils.GenerateKeyPair(tenant.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "生成工作空间密钥失败: " + err.Error()})
+		return
+	}
+	tenant.EncryptPublicKey = publicKeyPEM
+	publicKey, err := utils.ParsePublicKey(publicKeyPEM)
+	if err != nil {
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 在事务外完成凭据的加解密
+	if err := config.retarget(tenant.ID, privateKey, publicKey, now); err != nil {
+		deletePrivateKey(tenant.ID)
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	cloner := &tenantCloner{
+		tx:         tx,
+		tenantID:   tenant.ID,
+		ownerID:    owner.ID,
+		members:    map[string]bool{owner.ID: true},
+		privateKey: privateKey,
+		publicKey:  publicKey,
+		datasetIDs: map[string]string{},
+		now:        now,
+		report: &models.TenantCloneReport{
+			SourceTenantID: source.ID,
+			Providers:      len(config.Providers),
+			ProviderModels: len(config.Models),
+			Apps:           []models.ClonedObject{},
+			Datasets:       []models.ClonedObject{},
+			Notes:          []string{},
+		},
+	}
+
+	if err := cloner.run(tenant, joins, config, defaults, datasets, apps); err != nil {
+		tx.Rollback()
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := cloner.report
+	report.Tenant = models.NewTenantResponse(tenant)
+	report.Tenant.MemberCount = int64(report.Members)
+
+	before := gin.H{"source_tenant_id": source.ID}
+	if err := writeAuditLog(c, tx, AuditCloneTenant, AuditTargetTenant, tenant.ID, tenant.ID, before, report); err != nil {
+		tx.Rollback()
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, report)
+}
+
+// run 依次创建工作空间、成员关系、供应商配置、知识库和应用，知识库需要在应用之前复制，应用配置中的知识库 ID 才能替换为新 ID
+func (t *tenantCloner) run(tenant models.Tenant, joins []models.TenantAccountJoin, config *providerConfig,
+	defaults []models.TenantDefaultModel, datasets []models.Dataset, apps []models.App) error {
+	if err := t.tx.Create(&tenant).Error; err != nil {
+		return err
+	}
+
+	// 与 Dify 一样，创建工作空间时同时创建所有者成员关系
+	owner := models.TenantAccountJoin{
+		ID:        uuid.New().String(),
+		TenantID:  t.tenantID,
+		AccountID: t.ownerID,
+		Role:      models.TenantRoleOwner,
+		CreatedAt: t.now,
+		UpdatedAt: t.now,
+	}
+	if err := t.tx.Create(&owner).Error; err != nil {
+		return err
+	}
+	for _, join := range joins {
+		if t.members[join.AccountID] {
+			continue
+		}
+		// 新工作空间已有所有者，源工作空间的所有者改为 admin
+		role := join.Role
+		if role == models.TenantRoleOwner {
+			role = models.TenantRoleAdmin
+		}
+		member := models.TenantAccountJoin{
+			ID:        uuid.New().String(),
+			TenantID:  t.tenantID,
+			AccountID: join.AccountID,
+			Role:      role,
+			InvitedBy: &t.ownerID,
+			CreatedAt: t.now,
+			UpdatedAt: t.now,
+		}
+		if err := t.tx.Create(&member).Error; err != nil {
+			return err
+		}
+		t.members[join.AccountID] = true
+	}
+	for accountID := range t.members {
+		if err := ensureCurrentTenant(t.tx, accountID); err != nil {
+			return err
+		}
+	}
+	t.report.Members = len(t.members)
+
+	if err := config.create(t.tx); err != nil {
+		return err
+	}
+	for _, model := range defaults {
+		model.ID = uuid.New().String()
+		model.TenantID = t.tenantID
+		model.CreatedAt = t.now
+		model.UpdatedAt = t.now
+		if err := t.tx.Create(&model).Error; err != nil {
+			return err
+		}
+	}
+	t.report.DefaultModels = len(defaults)
+
+	for _, dataset := range datasets {
+		if err := t.cloneDataset(dataset); err != nil {
+			return fmt.Errorf("复制知识库 %s 失败: %w", dataset.Name, err)
+		}
+	}
+	for _, app := range apps {
+		if err := t.cloneApp(app); err != nil {
+			return fmt.Errorf("复制应用 %s 失败: %w", app.Name, err)
+		}
+	}
+	return nil
+}
+
+// cloneDataset 复制知识库的设置，不复制文档，index_struct 置空后 Dify 会为新知识库创建新的向量索引
+// 部分成员可见的知识库只保留新工作空间中的成员
+func (t *tenantCloner) cloneDataset(dataset models.Dataset) error {
+	id := uuid.New().String()
+	if err := cloneRow(t.tx, "datasets", dataset.ID, map[string]interface{}{
+		"id":           id,
+		"tenant_id":    t.tenantID,
+		"index_struct": nil,
+		"created_by":   t.ownerID,
+		"updated_by":   t.ownerID,
+		"created_at":   t.now,
+		"updated_at":   t.now,
+	}, nil, nil); err != nil {
+		return err
+	}
+	t.datasetIDs[dataset.ID] = id
+	t.report.Datasets = append(t.report.Datasets, models.ClonedObject{SourceID: dataset.ID, ID: id, Name: dataset.Name})
+
+	if dataset.Permission != models.DatasetPermissionPartialMembers {
+		return nil
+	}
+	var permissions []models.DatasetPermission
+	if err := t.tx.Where("dataset_id = ?", dataset.ID).Find(&permissions).Error; err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !t.members[permission.AccountID] {
+			continue
+		}
+		permission.ID = uuid.New().String()
+		permission.DatasetID = id
+		permission.TenantID = t.tenantID
+		permission.CreatedAt = t.now
+		if err := t.tx.Create(&permission).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneApp 复制应用及其模型配置、工作流（草稿和已发布版本）和公开站点
+// 配置和工作流中引用的已复制知识库替换为新知识库，引用未复制的知识库时记录到 Notes
+func (t *tenantCloner) cloneApp(app models.App) error {
+	id := uuid.New().String()
+	replacements := make([][2]string, 0, len(t.datasetIDs))
+	for oldID, newID := range t.datasetIDs {
+		replacements = append(replacements, [2]string{oldID, newID})
+	}
+
+	var configID *string
+	if app.AppModelConfigID != nil && t.tx.Migrator().HasTable("app_model_configs") {
+		newID := uuid.New().String()
+		configID = &newID
+		if err := cloneRow(t.tx, "app_model_configs", *app.AppModelConfigID, map[string]interface{}{
+			"id":         newID,
+			"app_id":     id,
+			"created_by": t.ownerID,
+			"updated_by": t.ownerID,
+			"created_at": t.now,
+			"updated_at": t.now,
+		}, []string{"dataset_configs", "agent_mode"}, replacements); err != nil {
+			return err
+		}
+	}
+
+	// 先复制应用，工作流和站点引用新应用的 ID
+	if err := cloneRow(t.tx, "apps", app.ID, map[string]interface{}{
+		"id":                  id,
+		"tenant_id":           t.tenantID,
+		"app_model_config_id": configID,
+		"workflow_id":         nil,
+		"created_by":          t.ownerID,
+		"updated_by":          t.ownerID,
+		"created_at":          t.now,
+		"updated_at":          t.now,
+	}, nil, nil); err != nil {
+		return err
+	}
+
+	if t.tx.Migrator().HasTable("workflows") {
+		var workflows []struct {
+			ID                   string
+			EnvironmentVariables *string
+		}
+		query := t.tx.Table("workflows").Where("app_id = ?", app.ID)
+		if app.WorkflowID != nil {
+			query = query.Where("(version = ? OR id = ?)", "draft", *app.WorkflowID)
+		} else {
+			query = query.Where("version = ?", "draft")
+		}
+		columns := "id"
+		hasEnvironment := t.tx.Migrator().HasColumn("workflows", "environment_variables")
+		if hasEnvironment {
+			columns += ", environment_variables::text AS environment_variables"
+		}
+		if err := query.Select(columns).Scan(&workflows).Error; err != nil {
+			return err
+		}
+		for _, workflow := range workflows {
+			newID := uuid.New().String()
+			overrides := map[string]interface{}{
+				"id":         newID,
+				"tenant_id":  t.tenantID,
+				"app_id":     id,
+				"created_by": t.ownerID,
+				"updated_by": t.ownerID,
+				"created_at": t.now,
+				"updated_at": t.now,
+			}
+			if hasEnvironment {
+				variables, err := reencryptEnvironmentVariables(workflow.EnvironmentVariables, t.privateKey, t.publicKey)
+				if err != nil {
+					return err
+				}
+				overrides["environment_variables"] = variables
+			}
+			if err := cloneRow(t.tx, "workflows", workflow.ID, overrides, []string{"graph"}, replacements); err != nil {
+				return err
+			}
+			if app.WorkflowID != nil && workflow.ID == *app.WorkflowID {
+				if err := t.tx.Table("apps").Where("id = ?", id).Update("workflow_id", newID).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if t.tx.Migrator().HasTable("sites") {
+		var siteIDs []string
+		if err := t.tx.Table("sites").Where("app_id = ?", app.ID).Pluck("id", &siteIDs).Error; err != nil {
+			return err
+		}
+		for _, siteID := range siteIDs {
+			code, err := t.siteCode()
+			if err != nil {
+				return err
+			}
+			if err := cloneRow(t.tx, "sites", siteID, map[string]interface{}{
+				"id":         uuid.New().String(),
+				"app_id":     id,
+				"code":       code,
+				"created_by": t.ownerID,
+				"updated_by": t.ownerID,
+				"created_at": t.now,
+				"updated_at": t.now,
+			}, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if t.tx.Migrator().HasTable("app_dataset_joins") {
+		var datasetIDs []string
+		if err := t.tx.Table("app_dataset_joins").Where("app_id = ?", app.ID).Pluck("dataset_id", &datasetIDs).Error; err != nil {
+			return err
+		}
+		for _, datasetID := range datasetIDs {
+			newID, ok := t.datasetIDs[datasetID]
+			if !ok {
+				t.report.Notes = append(t.report.Notes,
+					fmt.Sprintf("应用 %s 引用的知识库 %s 没有复制，需要在新应用中重新选择", app.Name, datasetID))
+				continue
+			}
+			if err := t.tx.Exec("INSERT INTO app_dataset_joins (id, app_id, dataset_id, created_at) VALUES (?, ?, ?, ?)",
+				uuid.New().String(), id, newID, t.now).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	t.report.Apps = append(t.report.Apps, models.ClonedObject{SourceID: app.ID, ID: id, Name: app.Name})
+	return nil
+}
+
+// siteCode 与 Dify 一样生成不重复的站点随机码
+func (t *tenantCloner) siteCode() (string, error) {
+	for {
+		code, err := randomString(siteCodeLength)
+		if err != nil {
+			return "", err
+		}
+		var count int64
+		if err := t.tx.Table("sites").Where("code = ?", code).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return code, nil
+		}
+	}
+}
+
+// cloneRow 复制表中 id 对应的一行，overrides 中的字段使用新值，其余字段原样复制
+// 按数据库中实际存在的字段生成语句，不同 Dify 版本的表结构不同时也能复制；overrides 中不存在的字段会被忽略
+// remap 中的字段把出现的旧 ID 替换为新 ID，例如应用配置和工作流中引用的知识库
+func cloneRow(tx *gorm.DB, table, id string, overrides map[string]interface{}, remap []string, replacements [][2]string) error {
+	columnTypes, err := tx.Migrator().ColumnTypes(table)
+	if err != nil {
+		return err
+	}
+	remapColumns := map[string]bool{}
+	for _, column := range remap {
+		remapColumns[column] = true
+	}
+
+	columns := make([]string, 0, len(columnTypes))
+	values := make([]string, 0, len(columnTypes))
+	var args []interface{}
+	for _, columnType := range columnTypes {
+		name := columnType.Name()
+		dbType := strings.ToLower(columnType.DatabaseTypeName())
+		columns = append(columns, `"`+name+`"`)
+
+		// 参数需要显式转换类型，否则 INSERT ... SELECT 中的参数会被当作 text
+		if value, ok := overrides[name]; ok {
+			values = append(values, "CAST(? AS "+dbType+")")
+			args = append(args, value)
+			continue
+		}
+		value := `"` + name + `"`
+		if remapColumns[name] && len(replacements) > 0 {
+			value += "::text"
+			for _, r := range replacements {
+				value = "REPLACE(" + value + ", ?, ?)"
+				args = append(args, r[0], r[1])
+			}
+			value = "CAST(" + value + " AS " + dbType + ")"
+		}
+		values = append(values, value)
+	}
+	args = append(args, id)
+
+	sql := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE id = ?",
+		table, strings.Join(columns, ", "), strings.Join(values, ", "), table)
+	result := tx.Exec(sql, args...)
+	if result.Error != nil {
+		return fmt.Errorf("复制 %s 失败: %w", table, result.Error)
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("复制 %s 失败: 未找到 %s", table, id)
+	}
+	return nil
+}
+
+// reencryptEnvironmentVariables 工作流环境变量中 secret 类型的值用工作空间公钥加密，复制到新工作空间时需要重新加密
+func reencryptEnvironmentVariables(variables *string, source *rsa.PrivateKey, target *rsa.PublicKey) (*string, error) {
+	if variables == nil || *variables == "" {
+		return variables, nil
+	}
+	var items []map[string]interface{}
+	if err := json.Unmarshal([]byte(*variables), &items); err != nil {
+		return nil, errors.New("工作流环境变量格式错误")
+	}
+	for _, item := range items {
+		value, ok := item["value"].(string)
+		if item["value_type"] != "secret" || !ok || value == "" {
+			continue
+		}
+		if source == nil {
+			return nil, errors.New("读取源工作空间私钥失败，无法复制加密的环境变量")
+		}
+		plaintext, err := utils.DecryptToken(source, value)
+		if err != nil {
+			return nil, fmt.Errorf("解密环境变量 %v 失败", item["name"])
+		}
+		if item["value"], err = utils.EncryptToken(target, plaintext); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	result := string(data)
+	return &result, nil
+}
+
+// uniqueStrings 去除重复的值
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// datasetTenantTables 带 tenant_id 和 dataset_id 字段、迁移知识库时需要同步修改 tenant_id 的表
+// 部分表只在较新的 Dify 版本中存在，不存在时跳过
+var datasetTenantTables = []string{
+	"documents",
+	"document_segments",
+	"child_chunks",
+	"dataset_metadatas",
+	"dataset_metadata_bindings",
+	"dataset_auto_disable_logs",
+}
+
+// moveDatasetRequest 迁移知识库的请求参数
+type moveDatasetRequest struct {
+	DatasetID string `json:"dataset_id"`
+	TenantID  string `json:"tenant_id"`
+	DryRun    bool   `json:"dry_run"` // 只统计会修改的数据，不提交
+}
+
+// MoveDataset 把知识库及其文档、分段、权限等关联数据迁移到另一个工作空间
+func MoveDataset(c *gin.Context) {
+	var req moveDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, ok := moveDataset(c, req, AuditMoveDataset)
+	if !ok {
+		return
+	}
+
+	message := "迁移知识库成功"
+	if req.DryRun {
+		message = "校验通过"
+	}
+	c.JSON(200, gin.H{"message": message, "report": report})
+}
+
+// moveDataset 在一个事务中修改知识库及所有依赖表的工作空间，失败时直接返回错误响应
+func moveDataset(c *gin.Context, req moveDatasetRequest, action string) (*models.DatasetMoveReport, bool) {
+	if req.DatasetID == "" || req.TenantID == "" {
+		c.JSON(400, gin.H{"error": "dataset_id 和 tenant_id 不能为空"})
+		return nil, false
+	}
+
+	// 目标工作空间和知识库当前所属工作空间都需要在管理范围内
+	if !requireTenantInScope(c, req.TenantID) {
+		return nil, false
+	}
+	var dataset models.Dataset
+	if err := database.DB.Where("id = ?", req.DatasetID).First(&dataset).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定知识库"})
+		return nil, false
+	}
+	if dataset.TenantID != "" && !requireTenantInScope(c, dataset.TenantID) {
+		return nil, false
+	}
+	if dataset.TenantID == req.TenantID {
+		c.JSON(400, gin.H{"error": "知识库已属于该工作空间"})
+		return nil, false
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Tenant{}).Where("id = ?", req.TenantID).Count(&count).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if count == 0 {
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return nil, false
+	}
+
+	// 外部知识库绑定的是原工作空间的外部知识库 API，不能直接迁移
+	if dataset.Provider == "external" {
+		c.JSON(400, gin.H{"error": "外部知识库不支持迁移"})
+		return nil, false
+	}
+
+	if err := checkEmbeddingProvider(database.DB, dataset, req.TenantID); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	// 仅创建者可见的知识库迁移后创建者需要能访问，否则没有人能看到该知识库
+	if dataset.Permission == models.DatasetPermissionOnlyMe {
+		if err := database.DB.Model(&models.TenantAccountJoin{}).
+			Where("tenant_id = ? AND account_id = ?", req.TenantID, dataset.CreatedBy).
+			Count(&count).Error; err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		if count == 0 {
+			c.JSON(400, gin.H{"error": "知识库仅创建者可见，但创建者不是目标工作空间的成员，请先修改可见范围或将创建者加入目标工作空间"})
+			return nil, false
+		}
+	}
+
+	report := &models.DatasetMoveReport{
+		DatasetID:    dataset.ID,
+		FromTenantID: dataset.TenantID,
+		ToTenantID:   req.TenantID,
+		DryRun:       req.DryRun,
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if err := moveDatasetRows(tx, dataset.ID, req.TenantID, report); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	// 只校验时回滚，返回的统计与实际执行时一致
+	if req.DryRun {
+		tx.Rollback()
+		return report, true
+	}
+
+	// 原工作空间和目标工作空间各记录一条，两边的工作空间管理员都能看到知识库的迁移
+	before := gin.H{"tenant_id": dataset.TenantID}
+	after := gin.H{"tenant_id": req.TenantID, "changes": report.Changes}
+	for _, tenantID := range []string{req.TenantID, dataset.TenantID} {
+		if tenantID == "" {
+			continue
+		}
+		if err := writeAuditLog(c, tx, action, AuditTargetDataset, dataset.ID, tenantID, before, after); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "写入审计日志失败"})
+			return nil, false
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	return report, true
+}
+
+// moveDatasetRows 修改知识库及依赖表中的数据，并把每张表的修改行数记录到 report
+func moveDatasetRows(tx *gorm.DB, datasetID, tenantID string, report *models.DatasetMoveReport) error {
+	m := newTableMover(tx)
+	defer func() {
+		report.Changes = m.changes
+		report.SkippedTables = m.skipped
+	}()
+
+	if err := m.exec("datasets", "update", "", "UPDATE datasets SET tenant_id = ? WHERE id = ?", tenantID, datasetID); err != nil {
+		return err
+	}
+
+	for _, table := range datasetTenantTables {
+		if !m.hasTable(table) {
+			continue
+		}
+		if err := m.exec(table, "update", "",
+			"UPDATE "+table+" SET tenant_id = ? WHERE dataset_id = ? AND tenant_id IS DISTINCT FROM ?",
+			tenantID, datasetID, tenantID); err != nil {
+			return err
+		}
+	}
+
+	// 上传的文件通过 documents.data_source_info 中的 upload_file_id 关联，文件路径保存在 key 中，只需修改 tenant_id
+	if m.hasTable("upload_files") {
+		if err := m.exec("upload_files", "update", "",
+			`UPDATE upload_files SET tenant_id = ? WHERE tenant_id IS DISTINCT FROM ? AND id::text IN (
+			SELECT data_source_info::json->>'upload_file_id' FROM documents
+			WHERE dataset_id = ? AND data_source_type = 'upload_file' AND data_source_info IS NOT NULL)`,
+			tenantID, tenantID, datasetID); err != nil {
+			return err
+		}
+	}
+
+	// 部分成员可见的权限只保留目标工作空间的成员
+	if m.hasTable("dataset_permissions") {
+		if err := m.exec("dataset_permissions", "delete", "不是目标工作空间成员的用户",
+			`DELETE FROM dataset_permissions WHERE dataset_id = ? AND account_id NOT IN (
+			SELECT account_id FROM tenant_account_joins WHERE tenant_id = ?)`, datasetID, tenantID); err != nil {
+			return err
+		}
+		if err := m.exec("dataset_permissions", "update", "",
+			"UPDATE dataset_permissions SET tenant_id = ? WHERE dataset_id = ? AND tenant_id IS DISTINCT FROM ?",
+			tenantID, datasetID, tenantID); err != nil {
+			return err
+		}
+	}
+
+	// 应用不能引用其他工作空间的知识库，删除原工作空间中应用的引用
+	if m.hasTable("app_dataset_joins") {
+		if err := m.exec("app_dataset_joins", "delete", "不属于目标工作空间的应用",
+			`DELETE FROM app_dataset_joins WHERE dataset_id = ? AND app_id NOT IN (
+			SELECT id FROM apps WHERE tenant_id = ?)`, datasetID, tenantID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkEmbeddingProvider 高质量索引的知识库需要目标工作空间配置了相同的嵌入模型供应商
+func checkEmbeddingProvider(db *gorm.DB, dataset models.Dataset, tenantID string) error {
+	if dataset.IndexingTechnique != "high_quality" || dataset.EmbeddingModelProvider == "" {
+		return nil
+	}
+
+	names := providerNameVariants(dataset.EmbeddingModelProvider)
+
+	var count int64
+	if err := db.Table("providers").
+		Where("tenant_id = ? AND provider_name IN ? AND is_valid = ?", tenantID, names, true).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	// 只单独配置了模型凭据的供应商
+	if err := db.Table("provider_models").
+		Where("tenant_id = ? AND provider_name IN ? AND model_name = ? AND is_valid = ?",
+			tenantID, names, dataset.EmbeddingModel, true).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return errors.New("目标工作空间没有配置嵌入模型供应商 " + dataset.EmbeddingModelProvider)
+}
+
+// providerNameVariants 供应商名称的两种写法：插件化之前的 openai 和插件化之后的 langgenius/openai/openai
+func providerNameVariants(name string) []string {
+	parts := strings.Split(name, "/")
+	if len(parts) == 1 {
+		return []string{name, "langgenius/" + name + "/" + name}
+	}
+	if len(parts) == 3 && parts[0] == "langgenius" {
+		return []string{name, parts[2]}
+	}
+	return []string{name}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"time"
+)
+
+// GetDatasetPermission 查看知识库的可见范围及部分成员列表
+func GetDatasetPermission(c *gin.Context) {
+	dataset, ok := requireDatasetInScope(c, c.Query("dataset_id"))
+	if !ok {
+		return
+	}
+
+	response := models.DatasetPermissionResponse{
+		DatasetID:  dataset.ID,
+		TenantID:   dataset.TenantID,
+		Permission: dataset.Permission,
+		CreatedBy:  dataset.CreatedBy,
+		Members:    []models.DatasetPermissionMember{},
+	}
+	if err := database.DB.Table("dataset_permissions AS p").
+		Select("p.account_id, a.email AS account_email, a.name AS account_name, j.role").
+		Joins("LEFT JOIN accounts a ON a.id = p.account_id").
+		Joins("LEFT JOIN tenant_account_joins j ON j.account_id = p.account_id AND j.tenant_id = ?", dataset.TenantID).
+		Where("p.dataset_id = ? AND p.has_permission = ?", dataset.ID, true).
+		Order("p.created_at").
+		Scan(&response.Members).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, response)
+}
+
+// SetDatasetPermission 修改知识库的可见范围，partial_members 时同时替换可以访问的成员列表
+// 与 Dify 一致，可见范围不是 partial_members 时清空成员列表
+func SetDatasetPermission(c *gin.Context) {
+	var req struct {
+		DatasetID  string   `json:"dataset_id"`
+		Permission string   `json:"permission"`
+		AccountIDs []string `json:"account_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Permission == "" {
+		c.JSON(400, gin.H{"error": "permission 不能为空"})
+		return
+	}
+	if !models.ValidDatasetPermissions[req.Permission] {
+		c.JSON(400, gin.H{"error": "无效的可见范围"})
+		return
+	}
+	if req.Permission == models.DatasetPermissionPartialMembers && len(req.AccountIDs) == 0 {
+		c.JSON(400, gin.H{"error": "请选择可以访问知识库的成员"})
+		return
+	}
+	if req.Permission != models.DatasetPermissionPartialMembers && len(req.AccountIDs) > 0 {
+		c.JSON(400, gin.H{"error": "只有 partial_members 可以指定成员"})
+		return
+	}
+
+	dataset, ok := requireDatasetInScope(c, req.DatasetID)
+	if !ok {
+		return
+	}
+
+	// 成员必须属于知识库所在的工作空间
+	accountIDs := make([]string, 0, len(req.AccountIDs))
+	seen := map[string]bool{}
+	for _, id := range req.AccountIDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			accountIDs = append(accountIDs, id)
+		}
+	}
+	if len(accountIDs) > 0 {
+		var members []string
+		if err := database.DB.Model(&models.TenantAccountJoin{}).
+			Where("tenant_id = ? AND account_id IN ?", dataset.TenantID, accountIDs).
+			Pluck("account_id", &members).Error; err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		isMember := map[string]bool{}
+		for _, id := range members {
+			isMember[id] = true
+		}
+		var invalid []string
+		for _, id := range accountIDs {
+			if !isMember[id] {
+				invalid = append(invalid, id)
+			}
+		}
+		if len(invalid) > 0 {
+			c.JSON(400, gin.H{"error": "以下用户不是知识库所在工作空间的成员", "account_ids": invalid})
+			return
+		}
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var before []string
+	if err := tx.Model(&models.DatasetPermission{}).Where("dataset_id = ?", dataset.ID).Pluck("account_id", &before).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Model(&models.Dataset{}).Where("id = ?", dataset.ID).
+		Updates(map[string]interface{}{"permission": req.Permission, "updated_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Where("dataset_id = ?", dataset.ID).Delete(&models.DatasetPermission{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "删除知识库成员失败"})
+		return
+	}
+	if len(accountIDs) > 0 {
+		now := time.Now()
+		permissions := make([]models.DatasetPermission, len(accountIDs))
+		for i, id := range accountIDs {
+			permissions[i] = models.DatasetPermission{
+				ID:            uuid.New().String(),
+				DatasetID:     dataset.ID,
+				AccountID:     id,
+				TenantID:      dataset.TenantID,
+				HasPermission: true,
+				CreatedAt:     now,
+			}
+		}
+		if err := tx.Create(&permissions).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "添加知识库成员失败"})
+			return
+		}
+	}
+
+	auditBefore := gin.H{"permission": dataset.Permission, "account_ids": before}
+	auditAfter := gin.H{"permission": req.Permission, "account_ids": accountIDs}
+	if err := writeAuditLog(c, tx, AuditSetDatasetPermission, AuditTargetDataset, dataset.ID, dataset.TenantID, auditBefore, auditAfter); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "修改知识库可见范围成功"})
+}
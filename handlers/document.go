@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"github.com/gin-gonic/gin"
+)
+
+// requireDatasetInScope 查询知识库并检查其所属工作空间是否在管理范围内，失败时直接返回错误响应
+func requireDatasetInScope(c *gin.Context, datasetID string) (models.Dataset, bool) {
+	var dataset models.Dataset
+	if datasetID == "" {
+		c.JSON(400, gin.H{"error": "dataset_id 参数必填"})
+		return dataset, false
+	}
+	if err := database.DB.Where("id = ?", datasetID).First(&dataset).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定知识库"})
+		return dataset, false
+	}
+	if !requireTenantInScope(c, dataset.TenantID) {
+		return dataset, false
+	}
+	return dataset, true
+}
+
+// GetDatasetDetail 知识库详情及文档、分段的统计
+func GetDatasetDetail(c *gin.Context) {
+	dataset, ok := requireDatasetInScope(c, c.Query("dataset_id"))
+	if !ok {
+		return
+	}
+
+	detail := models.DatasetDetailResponse{
+		Dataset:           models.NewDatasetResponse(dataset),
+		DocumentsByStatus: map[string]int64{},
+	}
+
+	var statuses []struct {
+		IndexingStatus string
+		Count          int64
+	}
+	if err := database.DB.Model(&models.Document{}).
+		Select("indexing_status, COUNT(*) AS count").
+		Where("dataset_id = ?", dataset.ID).
+		Group("indexing_status").
+		Scan(&statuses).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, status := range statuses {
+		detail.DocumentsByStatus[status.IndexingStatus] = status.Count
+		detail.DocumentCount += status.Count
+	}
+
+	var documents struct {
+		Disabled  int64
+		Archived  int64
+		Errors    int64
+		WordCount int64
+	}
+	if err := database.DB.Model(&models.Document{}).
+		Select("COUNT(*) FILTER (WHERE NOT enabled) AS disabled, "+
+			"COUNT(*) FILTER (WHERE archived) AS archived, "+
+			"COUNT(*) FILTER (WHERE error IS NOT NULL AND error <> '') AS errors, "+
+			"COALESCE(SUM(word_count), 0) AS word_count").
+		Where("dataset_id = ?", dataset.ID).
+		Scan(&documents).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	detail.DisabledDocuments = documents.Disabled
+	detail.ArchivedDocuments = documents.Archived
+	detail.ErrorDocuments = documents.Errors
+	detail.WordCount = documents.WordCount
+
+	var segments struct {
+		Total     int64
+		Enabled   int64
+		Completed int64
+	}
+	if err := database.DB.Model(&models.DocumentSegment{}).
+		Select("COUNT(*) AS total, "+
+			"COUNT(*) FILTER (WHERE enabled) AS enabled, "+
+			"COUNT(*) FILTER (WHERE status = 'completed') AS completed").
+		Where("dataset_id = ?", dataset.ID).
+		Scan(&segments).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	detail.SegmentCount = segments.Total
+	detail.EnabledSegmentCount = segments.Enabled
+	detail.CompletedSegmentCount = segments.Completed
+
+	c.JSON(200, detail)
+}
+
+// GetDocuments 知识库中的文档列表
+func GetDocuments(c *gin.Context) {
+	dataset, ok := requireDatasetInScope(c, c.Query("dataset_id"))
+	if !ok {
+		return
+	}
+
+	var documents []models.Document
+	var total int64
+
+	query, params, err := parseListQuery(c, database.DB.Model(&models.Document{}).Where("dataset_id = ?", dataset.ID), documentListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&documents)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	data, err := documentResponses(documents)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, params.response(data, total))
+}
+
+// GetDocumentSegments 文档的分段内容
+func GetDocumentSegments(c *gin.Context) {
+	documentID := c.Query("document_id")
+	if documentID == "" {
+		c.JSON(400, gin.H{"error": "document_id 参数必填"})
+		return
+	}
+
+	var document models.Document
+	if err := database.DB.Where("id = ?", documentID).First(&document).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定文档"})
+		return
+	}
+	if !requireTenantInScope(c, document.TenantID) {
+		return
+	}
+
+	var segments []models.DocumentSegment
+	var total int64
+
+	query, params, err := parseListQuery(c, database.DB.Model(&models.DocumentSegment{}).Where("document_id = ?", document.ID), segmentListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&segments)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(200, params.response(segmentResponses(segments), total))
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"strings"
+	"time"
+)
+
+// 导出格式
+const (
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+)
+
+// 导出中途出错时的标记：CSV 末尾写入第一列为 exportErrorMarker 的一行，
+// NDJSON 末尾写入 {"error": ...}，同时设置 exportErrorTrailer
+const (
+	exportErrorMarker  = "#export_error"
+	exportErrorTrailer = "X-Export-Error"
+)
+
+// exportColumn 导出文件中的一列，Value 返回该行在这一列的值
+type exportColumn[T any] struct {
+	Name  string
+	Value func(row T) interface{}
+}
+
+// 导出的列，不包含 Password、PasswordSalt 等敏感字段
+var (
+	accountExportColumns = []exportColumn[models.Account]{
+		{"id", func(a models.Account) interface{} { return a.ID }},
+		{"email", func(a models.Account) interface{} { return a.Email }},
+		{"name", func(a models.Account) interface{} { return a.Name }},
+		{"status", func(a models.Account) interface{} { return a.Status }},
+		{"interface_language", func(a models.Account) interface{} { return a.InterfaceLanguage }},
+		{"timezone", func(a models.Account) interface{} { return a.Timezone }},
+		{"initialized_at", func(a models.Account) interface{} { return a.InitializedAt }},
+		{"created_at", func(a models.Account) interface{} { return a.CreatedAt }},
+	}
+	tenantExportColumns = []exportColumn[models.Tenant]{
+		{"id", func(t models.Tenant) interface{} { return t.ID }},
+		{"name", func(t models.Tenant) interface{} { return t.Name }},
+		{"plan", func(t models.Tenant) interface{} { return t.Plan }},
+		{"status", func(t models.Tenant) interface{} { return t.Status }},
+		{"created_at", func(t models.Tenant) interface{} { return t.CreatedAt }},
+		{"updated_at", func(t models.Tenant) interface{} { return t.UpdatedAt }},
+	}
+	membershipExportColumns = []exportColumn[models.MembershipResponse]{
+		{"id", func(m models.MembershipResponse) interface{} { return m.ID }},
+		{"tenant_id", func(m models.MembershipResponse) interface{} { return m.TenantID }},
+		{"tenant_name", func(m models.MembershipResponse) interface{} { return m.TenantName }},
+		{"account_id", func(m models.MembershipResponse) interface{} { return m.AccountID }},
+		{"account_email", func(m models.MembershipResponse) interface{} { return m.AccountEmail }},
+		{"account_name", func(m models.MembershipResponse) interface{} { return m.AccountName }},
+		{"account_status", func(m models.MembershipResponse) interface{} { return m.AccountStatus }},
+		{"role", func(m models.MembershipResponse) interface{} { return m.Role }},
+		{"current", func(m models.MembershipResponse) interface{} { return m.Current }},
+		{"created_at", func(m models.MembershipResponse) interface{} { return m.CreatedAt }},
+	}
+	datasetExportColumns = []exportColumn[models.Dataset]{
+		{"id", func(d models.Dataset) interface{} { return d.ID }},
+		{"tenant_id", func(d models.Dataset) interface{} { return d.TenantID }},
+		{"name", func(d models.Dataset) interface{} { return d.Name }},
+		{"description", func(d models.Dataset) interface{} { return d.Description }},
+		{"provider", func(d models.Dataset) interface{} { return d.Provider }},
+		{"permission", func(d models.Dataset) interface{} { return d.Permission }},
+		{"data_source_type", func(d models.Dataset) interface{} { return d.DataSourceType }},
+		{"indexing_technique", func(d models.Dataset) interface{} { return d.IndexingTechnique }},
+		{"embedding_model", func(d models.Dataset) interface{} { return d.EmbeddingModel }},
+		{"embedding_model_provider", func(d models.Dataset) interface{} { return d.EmbeddingModelProvider }},
+		{"created_by", func(d models.Dataset) interface{} { return d.CreatedBy }},
+		{"created_at", func(d models.Dataset) interface{} { return d.CreatedAt }},
+		{"updated_at", func(d models.Dataset) interface{} { return d.UpdatedAt }},
+	}
+)
+
+func ExportAccounts(c *gin.Context) {
+	query, err := scopeByAccount(c, database.DB.Model(&models.Account{}), "id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	streamExport(c, "accounts", query.Order("created_at, id"), accountExportColumns)
+}
+
+func ExportTenants(c *gin.Context) {
+	query, err := scopeByTenant(c, database.DB.Model(&models.Tenant{}), "id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	streamExport(c, "tenants", query.Order("created_at, id"), tenantExportColumns)
+}
+
+func ExportTenantAccounts(c *gin.Context) {
+	query, err := scopeByTenant(c, membershipQuery().Select(membershipColumns), "j.tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	streamExport(c, "tenant_accounts", query.Order("j.tenant_id, j.created_at"), membershipExportColumns)
+}
+
+func ExportDatasets(c *gin.Context) {
+	query, err := scopeByTenant(c, database.DB.Model(&models.Dataset{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	streamExport(c, "datasets", query.Order("created_at, id"), datasetExportColumns)
+}
+
+// streamExport 逐行读取查询结果并以 CSV 或 NDJSON 格式写出，不会一次性加载全部数据
+// 通过 format 参数选择格式，默认 csv
+// 写出响应头之前先读取第一行，查询出错时仍可返回 500；之后出错时在文件末尾写入错误标记行，
+// 并通过 X-Export-Error trailer 返回错误信息，客户端据此判断文件不完整
+func streamExport[T any](c *gin.Context, name string, query *gorm.DB, columns []exportColumn[T]) {
+	format := c.DefaultQuery("format", exportFormatCSV)
+	if format != exportFormatCSV && format != exportFormatNDJSON {
+		c.JSON(400, gin.H{"error": "format 只支持 csv 和 ndjson"})
+		return
+	}
+
+	rows, err := query.Rows()
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	var first T
+	hasFirst := rows.Next()
+	if hasFirst {
+		if err := query.ScanRows(rows, &first); err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	} else if err := rows.Err(); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102150405"), format)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Header("Trailer", exportErrorTrailer)
+	if format == exportFormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		// 写入 BOM，Excel 打开时能正确识别中文
+		c.Writer.WriteString("\xef\xbb\xbf")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	}
+	c.Status(200)
+
+	csvWriter := csv.NewWriter(c.Writer)
+	encoder := json.NewEncoder(c.Writer)
+	if format == exportFormatCSV {
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Name
+		}
+		csvWriter.Write(header)
+	}
+
+	writeRow := func(row T) error {
+		if format == exportFormatCSV {
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				record[i] = exportCell(column.Value(row))
+			}
+			return csvWriter.Write(record)
+		}
+		item := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			item[column.Name] = column.Value(row)
+		}
+		return encoder.Encode(item)
+	}
+
+	var exportErr error
+	if hasFirst {
+		exportErr = writeRow(first)
+		count := 1
+		for exportErr == nil && rows.Next() {
+			var row T
+			if err := query.ScanRows(rows, &row); err != nil {
+				exportErr = err
+				break
+			}
+			exportErr = writeRow(row)
+
+			// 每 500 行刷新一次，数据量大时客户端可以边接收边处理
+			count++
+			if count%500 == 0 {
+				csvWriter.Flush()
+				c.Writer.Flush()
+			}
+		}
+		if exportErr == nil {
+			exportErr = rows.Err()
+		}
+	}
+
+	if exportErr != nil {
+		log.Println("导出数据失败:", exportErr)
+		// 响应头已经写出，无法再修改状态码
+		if format == exportFormatCSV {
+			csvWriter.Write([]string{exportErrorMarker, exportErr.Error()})
+		} else {
+			encoder.Encode(gin.H{"error": exportErr.Error()})
+		}
+		c.Writer.Header().Set(exportErrorTrailer, exportErr.Error())
+	}
+
+	csvWriter.Flush()
+	c.Writer.Flush()
+}
+
+// exportCell 把值格式化为 CSV 单元格文本
+func exportCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return escapeFormula(v)
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return escapeFormula(*v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeFormula 在以 =、+、-、@ 等开头的文本前加单引号，
+// 防止邮箱、名称等用户填写的内容在 Excel 中被当作公式执行
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
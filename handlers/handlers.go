@@ -1,213 +1,320 @@
 package handlers
 
 import (
-	"bytes"
 	"crypto/rand"
 	"crypto/sha256"
-	"difyserver/config"
 	"difyserver/database"
+	"difyserver/middleware"
 	"difyserver/models"
 	"difyserver/utils"
 	"encoding/base64"
 	"encoding/hex"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/pbkdf2"
-	"math"
-	"strconv"
+	"strings"
 	"time"
 )
 
 func GetAccounts(c *gin.Context) {
 	var accounts []models.Account
 	var total int64
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-	limit := 10
-	offset := (page - 1) * limit
+
+	// 受限管理员只能查看其管理的工作空间中的用户
+	query, err := scopeByAccount(c, database.DB.Model(&models.Account{}), "id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, accountListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 先获取总记录数
-	database.DB.Model(&models.Account{}).Count(&total)
+	query.Count(&total)
 
 	// 获取分页数据
-	result := database.DB.Limit(limit).Offset(offset).Find(&accounts)
+	result := params.paginate(query).Find(&accounts)
 	if result.Error != nil {
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	// 计算总页数
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	response := models.PageResponse{
-		Data:       accounts,
-		Total:      total,
-		TotalPages: totalPages,
-		Page:       page,
-		PageSize:   limit,
+	data, err := accountResponses(accounts)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(200, response)
+	c.JSON(200, params.response(data, total))
 }
 
 func AddAccount(c *gin.Context) {
-	var account models.Account
-	if err := c.ShouldBindJSON(&account); err != nil {
+	var req createAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	account.ID = uuid.New().String()
-	account.InterfaceLanguage = "zh-Hans"
-	account.Avatar = "99371728-eb21-49b2-a83c-26303f7c11b2"
-	account.InterfaceTheme = "light"
-	account.Timezone = "Asia/Shanghai"
-	account.Status = "active"
-	if result := database.DB.Create(&account); result.Error != nil {
-		c.JSON(500, gin.H{"error": result.Error.Error()})
+	if status, err := req.validate(c); err != nil {
+		c.JSON(status, passwordErrorResponse(err))
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	account, joins, err := req.create(tx)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, account)
+	// 审计日志中不记录密码
+	after := gin.H{"account": models.NewAccountResponse(account), "tenant_account_joins": joins}
+	if err := writeAuditLog(c, tx, AuditAddAccount, AuditTargetAccount, account.ID, "", nil, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, models.NewAccountResponse(account))
 }
 
 func GetTenants(c *gin.Context) {
 	var tenants []models.Tenant
 	var total int64
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-	limit := 10
-	offset := (page - 1) * limit
+
+	query, err := scopeByTenant(c, database.DB.Model(&models.Tenant{}), "id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, tenantListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 先获取总记录数
-	database.DB.Model(&models.Tenant{}).Count(&total)
+	query.Count(&total)
 
 	// 获取分页数据
-	result := database.DB.Limit(limit).Offset(offset).Find(&tenants)
+	result := params.paginate(query).Find(&tenants)
 	if result.Error != nil {
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	// 计算总页数
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	response := models.PageResponse{
-		Data:       tenants,
-		Total:      total,
-		TotalPages: totalPages,
-		Page:       page,
-		PageSize:   limit,
+	data, err := tenantResponses(tenants)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
 
-	c.JSON(200, response)
+	c.JSON(200, params.response(data, total))
 }
 
 func AddTenant(c *gin.Context) {
-	var tenant models.Tenant
-	if err := c.ShouldBindJSON(&tenant); err != nil {
+	var req struct {
+		Name    string `json:"name"`
+		Plan    string `json:"plan"`
+		Status  string `json:"status"`
+		OwnerID string `json:"owner_id"` // 工作空间所有者，默认为当前管理员
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	tenant.ID = uuid.New().String()
-	tenant.CreatedAt = time.Now()
-	tenant.UpdatedAt = time.Now()
-	tenant.EncryptPublicKey = "-----BEGIN PUBLIC KEY-----\nMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA6DgcAPwYgeVRla/LH/S9\n9TQ6MmQNZRO7PRilu8NdQxRO4UP9KvRaIE8Jv0TozcbvqyTx7rjYU5nQsEvbRh6s\ntoq3Id7+pF/rQZX1DWCsg9Tn9rCkwBdZLd4dA2/5I6AWYjMQtPf5XBFDfIf+hgBQ\ns8pSrmDO+g1LTD8qwcbx/VzsSR7SMxL7voPxByr5kUtyG+K80OkDl7ruddzdbUG3\nLF9VQvaiw7ocMVGN+FE/wvPPbtnTuQ1bkE0h771huTYGJ93kL9hd9SlpkYcLpUWP\nit/6tjkt7M8Z3DUJpdCMYeMjmaWuENBEKu8DFpehf7n3UoCo56Luqi4TNEkcG9Df\nuQIDAQAB\n-----END PUBLIC KEY-----"
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(400, gin.H{"error": "工作空间名称不能为空"})
+		return
+	}
+	if req.Plan == "" {
+		req.Plan = "basic"
+	}
+	if req.Status == "" {
+		req.Status = models.TenantStatusNormal
+	} else if !models.ValidTenantStatuses[req.Status] {
+		c.JSON(400, gin.H{"error": "无效的工作空间状态"})
+		return
+	}
+	if req.OwnerID == "" {
+		req.OwnerID = c.GetString("userID")
+	}
 
-	if result := database.DB.Create(&tenant); result.Error != nil {
+	// 受限管理员不能创建新的工作空间
+	if _, restricted, err := tenantScope(c); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	} else if restricted {
+		c.JSON(403, gin.H{"error": "没有权限创建工作空间"})
+		return
+	}
+
+	var owner models.Account
+	if err := database.DB.Where("id = ?", req.OwnerID).First(&owner).Error; err != nil {
+		c.JSON(400, gin.H{"error": "所有者用户不存在"})
+		return
+	}
+
+	now := time.Now()
+	tenant := models.Tenant{
+		ID:        uuid.New().String(),
+		Name:      req.Name,
+		Plan:      req.Plan,
+		Status:    req.Status,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	// 生成工作空间的密钥对，私钥保存到与 Dify 共用的存储中
+	publicKey, err := utils.GenerateKeyPair(tenant.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "生成工作空间密钥失败: " + err.Error()})
+		return
+	}
+	tenant.EncryptPublicKey = publicKey
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if result := tx.Create(&tenant); result.Error != nil {
+		tx.Rollback()
+		deletePrivateKey(tenant.ID)
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	c.JSON(200, tenant)
+	// 与 Dify 一样，创建工作空间时同时创建所有者成员关系
+	join := models.TenantAccountJoin{
+		ID:        uuid.New().String(),
+		TenantID:  tenant.ID,
+		AccountID: owner.ID,
+		Role:      models.TenantRoleOwner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := tx.Create(&join).Error; err != nil {
+		tx.Rollback()
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := ensureCurrentTenant(tx, owner.ID); err != nil {
+		tx.Rollback()
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	after := gin.H{"tenant": models.NewTenantResponse(tenant), "owner": join}
+	if err := writeAuditLog(c, tx, AuditAddTenant, AuditTargetTenant, tenant.ID, tenant.ID, nil, after); err != nil {
+		tx.Rollback()
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		deletePrivateKey(tenant.ID)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := models.NewTenantResponse(tenant)
+	response.MemberCount = 1
+	c.JSON(200, response)
 }
 
 func GetDatasets(c *gin.Context) {
 	var datasets []models.Dataset
 	var total int64
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-	limit := 10
-	offset := (page - 1) * limit
+
+	query, err := scopeByTenant(c, database.DB.Model(&models.Dataset{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, datasetListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 先获取总记录数
-	database.DB.Model(&models.Dataset{}).Count(&total)
+	query.Count(&total)
 
 	// 获取分页数据
-	result := database.DB.Limit(limit).Offset(offset).Find(&datasets)
+	result := params.paginate(query).Find(&datasets)
 	if result.Error != nil {
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	// 计算总页数
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	response := models.PageResponse{
-		Data:       datasets,
-		Total:      total,
-		TotalPages: totalPages,
-		Page:       page,
-		PageSize:   limit,
-	}
-
-	c.JSON(200, response)
+	c.JSON(200, params.response(datasetResponses(datasets), total))
 }
 
 func ListDatasetTenant(c *gin.Context) {
 	var datasets []models.Dataset
 	var total int64
 	tenantID := c.Query("tenant_id")
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-	limit := 10
-	offset := (page - 1) * limit
-
-	// 先获取总记录数
-	database.DB.Model(&models.Dataset{}).Count(&total)
 
-	// 获取分页数据
-	query := database.DB.Model(&models.Dataset{})
+	query, err := scopeByTenant(c, database.DB.Model(&models.Dataset{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
 	if tenantID != "" {
+		if !requireTenantInScope(c, tenantID) {
+			return
+		}
 		query = query.Where("tenant_id = ?", tenantID)
 	}
-
-	result := query.Limit(limit).Offset(offset).Find(&datasets)
-	if result.Error != nil {
-		c.JSON(500, gin.H{"error": result.Error.Error()})
+	query, params, err := parseListQuery(c, query, datasetListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 计算总页数
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	// 先获取总记录数
+	query.Count(&total)
 
-	response := models.PageResponse{
-		Data:       datasets,
-		Total:      total,
-		TotalPages: totalPages,
-		Page:       page,
-		PageSize:   limit,
+	// 获取分页数据
+	result := params.paginate(query).Find(&datasets)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
 	}
 
-	c.JSON(200, response)
+	c.JSON(200, params.response(datasetResponses(datasets), total))
 }
 
+// AddDatasetTenant 把知识库关联到工作空间，与 MoveDataset 相同，会同时迁移文档、分段等关联数据
 func AddDatasetTenant(c *gin.Context) {
-	var dataset models.Dataset
-	if err := c.ShouldBindJSON(&dataset); err != nil {
+	var req moveDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	result := database.DB.Model(&models.Dataset{}).Where("id = ?", dataset.ID).Update("tenant_id", dataset.TenantID)
-	if result.Error != nil {
-		c.JSON(500, gin.H{"error": result.Error.Error()})
+	report, ok := moveDataset(c, req, AuditAddDatasetTenant)
+	if !ok {
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "关联成功"})
+	c.JSON(200, gin.H{"message": "关联成功", "report": report})
 }
 
 func DelDatasetTenant(c *gin.Context) {
@@ -217,116 +324,132 @@ func DelDatasetTenant(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Model(&models.Dataset{}).Where("id = ? AND tenant_id = ?", dataset.ID, dataset.TenantID).Update("tenant_id", nil)
+	if !requireTenantInScope(c, dataset.TenantID) {
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	result := tx.Model(&models.Dataset{}).Where("id = ? AND tenant_id = ?", dataset.ID, dataset.TenantID).Update("tenant_id", nil)
 	if result.Error != nil {
+		tx.Rollback()
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
+	if result.RowsAffected > 0 {
+		before := gin.H{"tenant_id": dataset.TenantID}
+		after := gin.H{"tenant_id": nil}
+		if err := writeAuditLog(c, tx, AuditDelDatasetTenant, AuditTargetDataset, dataset.ID, dataset.TenantID, before, after); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "写入审计日志失败"})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(200, gin.H{"message": "删除关联成功"})
 }
 
 func ListTenantAccount(c *gin.Context) {
-	var joins []models.TenantAccountJoin
+	var joins []models.MembershipResponse
 	var total int64
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-	limit := 10
-	offset := (page - 1) * limit
+
+	query, err := scopeByTenant(c, membershipQuery(), "j.tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, tenantAccountListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 先获取总记录数
-	database.DB.Model(&models.TenantAccountJoin{}).Count(&total)
+	query.Count(&total)
 
 	// 获取分页数据
-	result := database.DB.Limit(limit).Offset(offset).Find(&joins)
+	result := params.paginate(query.Select(membershipColumns)).Scan(&joins)
 	if result.Error != nil {
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	// 计算总页数
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	response := models.PageResponse{
-		Data:       joins,
-		Total:      total,
-		TotalPages: totalPages,
-		Page:       page,
-		PageSize:   limit,
-	}
-
-	c.JSON(200, response)
+	c.JSON(200, params.response(joins, total))
 }
 
 func ListTenantAccountByAccount(c *gin.Context) {
-	var joins []models.TenantAccountJoin
+	var joins []models.MembershipResponse
 	var total int64
 	accountID := c.Query("account_id")
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-	limit := 10
-	offset := (page - 1) * limit
 
 	if accountID == "" {
 		c.JSON(400, gin.H{"error": "account_id 参数必填"})
 		return
 	}
+
+	// 受限管理员只能看到该用户在其管理的工作空间中的关联关系
+	query, err := scopeByTenant(c, membershipQuery(), "j.tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query.Where("j.account_id = ?", accountID), tenantAccountListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 先获取总记录数
-	database.DB.Model(&models.TenantAccountJoin{}).Count(&total)
+	query.Count(&total)
 
 	// 获取分页数据
-	result := database.DB.Where("account_id = ?", accountID).Limit(limit).Offset(offset).Find(&joins)
+	result := params.paginate(query.Select(membershipColumns)).Scan(&joins)
 	if result.Error != nil {
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
-	// 计算总页数
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
 
-	response := models.PageResponse{
-		Data:       joins,
-		Total:      total,
-		TotalPages: totalPages,
-		Page:       page,
-		PageSize:   limit,
-	}
-	c.JSON(200, response)
+	c.JSON(200, params.response(joins, total))
 }
 
 func ListTenantAccountByTenant(c *gin.Context) {
-	var joins []models.TenantAccountJoin
+	var joins []models.MembershipResponse
 	var total int64
 	tenantID := c.Query("tenant_id")
-	pageStr := c.DefaultQuery("page", "1")
-	page, _ := strconv.Atoi(pageStr)
-	limit := 10
-	offset := (page - 1) * limit
 
 	if tenantID == "" {
 		c.JSON(400, gin.H{"error": "tenant_id 参数必填"})
 		return
 	}
+	if !requireTenantInScope(c, tenantID) {
+		return
+	}
+
+	query, params, err := parseListQuery(c, membershipQuery().Where("j.tenant_id = ?", tenantID), tenantAccountListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 先获取总记录数
-	database.DB.Model(&models.TenantAccountJoin{}).Count(&total)
+	query.Count(&total)
 
 	// 获取分页数据
-	result := database.DB.Where("tenant_id = ?", tenantID).Limit(limit).Offset(offset).Find(&joins)
+	result := params.paginate(query.Select(membershipColumns)).Scan(&joins)
 	if result.Error != nil {
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	// 计算总页数
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-	response := models.PageResponse{
-		Data:       joins,
-		Total:      total,
-		TotalPages: totalPages,
-		Page:       page,
-		PageSize:   limit,
-	}
-	c.JSON(200, response)
+	c.JSON(200, params.response(joins, total))
 }
 
 func AddTenantAccount(c *gin.Context) {
@@ -347,19 +470,71 @@ func AddTenantAccount(c *gin.Context) {
 	}
 
 	// 验证角色值是否有效
-	validRoles := map[string]bool{
-		"owner":  true,
-		"admin":  true,
-		"editor": true,
-		"normal": true,
-	}
 	if req.Role == "" {
-		req.Role = "normal" // 默认角色
-	} else if !validRoles[req.Role] {
+		req.Role = models.TenantRoleNormal // 默认角色
+	} else if !validTenantRoles[req.Role] {
 		c.JSON(400, gin.H{"error": "无效的角色值"})
 		return
 	}
 
+	if !requireTenantInScope(c, req.TenantID) {
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var count int64
+	if err := tx.Model(&models.Account{}).Where("id = ?", req.AccountID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定用户"})
+		return
+	}
+	if err := tx.Model(&models.Tenant{}).Where("id = ?", req.TenantID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return
+	}
+
+	// 同一用户在同一工作空间只能有一条关联关系
+	if err := tx.Model(&models.TenantAccountJoin{}).
+		Where("tenant_id = ? AND account_id = ?", req.TenantID, req.AccountID).
+		Count(&count).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if count > 0 {
+		tx.Rollback()
+		c.JSON(409, gin.H{"error": "该用户已是工作空间成员"})
+		return
+	}
+
+	// 已有所有者时需要通过转移所有者接口修改
+	if req.Role == models.TenantRoleOwner {
+		hasOwner, err := tenantHasOwner(tx, req.TenantID, "")
+		if err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if hasOwner {
+			tx.Rollback()
+			c.JSON(400, gin.H{"error": "工作空间已有所有者，请使用转移所有者功能"})
+			return
+		}
+	}
+
 	join := models.TenantAccountJoin{
 		ID:        uuid.New().String(),
 		TenantID:  req.TenantID,
@@ -369,12 +544,37 @@ func AddTenantAccount(c *gin.Context) {
 		UpdatedAt: time.Now(),
 	}
 
-	if result := database.DB.Create(&join); result.Error != nil {
+	if result := tx.Create(&join); result.Error != nil {
+		tx.Rollback()
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	c.JSON(200, join)
+	// 用户还没有当前工作空间时，把新加入的工作空间设为当前工作空间
+	if err := ensureCurrentTenant(tx, req.AccountID); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Where("id = ?", join.ID).First(&join).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := writeAuditLog(c, tx, AuditAddTenantAccount, AuditTargetTenantAccount, req.AccountID, req.TenantID, nil, join); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, models.NewTenantAccountJoinResponse(join))
 }
 
 func DelTenantAccount(c *gin.Context) {
@@ -392,12 +592,67 @@ func DelTenantAccount(c *gin.Context) {
 		return
 	}
 
-	result := database.DB.Where("tenant_id = ? AND account_id = ?", req.TenantID, req.AccountID).Delete(&models.TenantAccountJoin{})
+	if !requireTenantInScope(c, req.TenantID) {
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var joins []models.TenantAccountJoin
+	if err := tx.Where("tenant_id = ? AND account_id = ?", req.TenantID, req.AccountID).Find(&joins).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 不能移除工作空间最后一个所有者
+	for _, join := range joins {
+		if join.Role != models.TenantRoleOwner {
+			continue
+		}
+		hasOwner, err := tenantHasOwner(tx, req.TenantID, req.AccountID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if !hasOwner {
+			tx.Rollback()
+			c.JSON(400, gin.H{"error": "不能移除工作空间的所有者，请先转移所有者"})
+			return
+		}
+		break
+	}
+
+	result := tx.Where("tenant_id = ? AND account_id = ?", req.TenantID, req.AccountID).Delete(&models.TenantAccountJoin{})
 	if result.Error != nil {
+		tx.Rollback()
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
+	// 移除的是当前工作空间时，为用户重新选择当前工作空间
+	if err := ensureCurrentTenant(tx, req.AccountID); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(joins) > 0 {
+		if err := writeAuditLog(c, tx, AuditDelTenantAccount, AuditTargetTenantAccount, req.AccountID, req.TenantID, joins, nil); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "写入审计日志失败"})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(200, gin.H{"message": "删除成功"})
 }
 
@@ -419,29 +674,68 @@ func UpdateTenantAccountRole(c *gin.Context) {
 	}
 
 	// 验证角色值是否有效
-	validRoles := map[string]bool{
-		"owner":  true,
-		"admin":  true,
-		"editor": true,
-		"normal": true,
-	}
-	if !validRoles[req.Role] {
+	if !validTenantRoles[req.Role] {
 		c.JSON(400, gin.H{"error": "无效的角色值"})
 		return
 	}
 
+	// 所有者只能通过转移所有者接口修改
+	if req.Role == models.TenantRoleOwner {
+		c.JSON(400, gin.H{"error": "请使用转移所有者功能设置工作空间所有者"})
+		return
+	}
+
+	if !requireTenantInScope(c, req.TenantID) {
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var join models.TenantAccountJoin
+	if err := tx.Where("tenant_id = ? AND account_id = ?", req.TenantID, req.AccountID).First(&join).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定的关联关系"})
+		return
+	}
+
+	// 不能降级工作空间最后一个所有者
+	if join.Role == models.TenantRoleOwner {
+		hasOwner, err := tenantHasOwner(tx, req.TenantID, req.AccountID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if !hasOwner {
+			tx.Rollback()
+			c.JSON(400, gin.H{"error": "不能修改工作空间所有者的角色，请先转移所有者"})
+			return
+		}
+	}
+
 	// 更新角色
-	result := database.DB.Model(&models.TenantAccountJoin{}).
+	result := tx.Model(&models.TenantAccountJoin{}).
 		Where("tenant_id = ? AND account_id = ?", req.TenantID, req.AccountID).
 		Update("role", req.Role)
 
 	if result.Error != nil {
+		tx.Rollback()
 		c.JSON(500, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(404, gin.H{"error": "未找到指定的关联关系"})
+	before := gin.H{"role": join.Role}
+	after := gin.H{"role": req.Role}
+	if err := writeAuditLog(c, tx, AuditUpdateTenantAccountRole, AuditTargetTenantAccount, req.AccountID, req.TenantID, before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -462,9 +756,45 @@ func DelAccount(c *gin.Context) {
 		return
 	}
 
+	if !requireAccountInScope(c, req.ID) {
+		return
+	}
+
 	// 开启事务
 	tx := database.DB.Begin()
 
+	// 记录删除前的用户及其关联关系
+	var account models.Account
+	if err := tx.Where("id = ?", req.ID).First(&account).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定用户"})
+		return
+	}
+	var joins []models.TenantAccountJoin
+	if err := tx.Where("account_id = ?", req.ID).Find(&joins).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "查询用户关联关系失败"})
+		return
+	}
+
+	// 用户是工作空间唯一的所有者时不能删除，需要先转移所有者
+	for _, join := range joins {
+		if join.Role != models.TenantRoleOwner {
+			continue
+		}
+		hasOwner, err := tenantHasOwner(tx, join.TenantID, req.ID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if !hasOwner {
+			tx.Rollback()
+			c.JSON(400, gin.H{"error": "该用户是工作空间 " + join.TenantID + " 的所有者，请先转移所有者"})
+			return
+		}
+	}
+
 	// 先删除关联关系
 	if err := tx.Where("account_id = ?", req.ID).Delete(&models.TenantAccountJoin{}).Error; err != nil {
 		tx.Rollback()
@@ -472,6 +802,28 @@ func DelAccount(c *gin.Context) {
 		return
 	}
 
+	// 删除 DifyServer 自有的管理员角色、工作空间绑定、密码历史和邀请记录，
+	// 避免之后使用相同ID或邮箱创建的用户继承管理员权限
+	var adminRoles []models.AdminRole
+	if err := tx.Where("account_id = ?", req.ID).Find(&adminRoles).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	var adminTenantIDs []string
+	if err := tx.Model(&models.AdminTenant{}).Where("account_id = ?", req.ID).Pluck("tenant_id", &adminTenantIDs).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, model := range []interface{}{&models.AdminRole{}, &models.AdminTenant{}, &models.PasswordHistory{}, &models.Invitation{}} {
+		if err := tx.Where("account_id = ?", req.ID).Delete(model).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "删除用户关联数据失败"})
+			return
+		}
+	}
+
 	// 再删除用户
 	if err := tx.Where("id = ?", req.ID).Delete(&models.Account{}).Error; err != nil {
 		tx.Rollback()
@@ -479,6 +831,13 @@ func DelAccount(c *gin.Context) {
 		return
 	}
 
+	before := gin.H{"account": models.NewAccountResponse(account), "tenant_account_joins": joins, "admin_roles": adminRoles, "admin_tenant_ids": adminTenantIDs}
+	if err := writeAuditLog(c, tx, AuditDelAccount, AuditTargetAccount, req.ID, "", before, nil); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(500, gin.H{"error": "删除用户失败"})
@@ -504,9 +863,7 @@ func SetAccountPassword(c *gin.Context) {
 		return
 	}
 
-	// 验证新密码长度
-	if len(req.NewPassword) < 6 {
-		c.JSON(400, gin.H{"error": "密码长度至少6位"})
+	if !requireAccountInScope(c, req.ID) {
 		return
 	}
 
@@ -517,30 +874,57 @@ func SetAccountPassword(c *gin.Context) {
 		return
 	}
 
-	// 生成新的盐值
-	salt := make([]byte, 16)
-	if _, err := rand.Read(salt); err != nil {
-		c.JSON(500, gin.H{"error": "生成密码盐失败"})
+	// 按密码安全策略检查新密码
+	if err := checkPassword(database.DB, req.NewPassword, &account); err != nil {
+		respondPasswordError(c, err)
 		return
 	}
 
-	// 使用新盐值加密新密码
-	byteArray := hashPassword(req.NewPassword, salt)
-
-	// 将密码和盐值转换为 base64 编码
-	hashedPassword := []byte(byteArray)
-	fmt.Println(byteArray) // 输出: [104 101 108 108 111]
+	// 生成新的盐值并加密密码
+	base64Password, base64Salt, err := encodePassword(req.NewPassword)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "生成密码盐失败"})
+		return
+	}
 
-	base64Password := base64.StdEncoding.EncodeToString(hashedPassword)
-	base64Salt := base64.StdEncoding.EncodeToString(salt)
+	// 开启事务
+	tx := database.DB.Begin()
 
 	// 更新用户密码和盐值
-	result := database.DB.Model(&account).Updates(map[string]interface{}{
+	result := tx.Model(&account).Updates(map[string]interface{}{
 		"password":      base64Password,
 		"password_salt": base64Salt,
 	})
 
 	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "设置密码失败"})
+		return
+	}
+	if err := recordPasswordHistory(tx, account.ID, base64Password, base64Salt); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 重置密码后该用户已登录的会话全部失效
+	if err := revokeAccountTokens(tx, account.ID, "password_reset"); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 审计日志只记录是否设置过密码，不记录密码内容
+	before := gin.H{"password_set": account.Password != ""}
+	after := gin.H{"password_set": true}
+	if err := writeAuditLog(c, tx, AuditSetAccountPassword, AuditTargetAccount, account.ID, "", before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
 		c.JSON(500, gin.H{"error": "设置密码失败"})
 		return
 	}
@@ -564,71 +948,106 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	isAdmin := false
-	for _, adminEmail := range config.GlobalConfig.Admins {
-		if adminEmail == req.Email {
-			isAdmin = true
-			break
-		}
+	// 来源 IP 或邮箱连续失败过多时先拒绝，不校验密码
+	attempt, err := beginLoginAttempt(c, req.Email)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
 	}
-
-	if !isAdmin {
-		c.JSON(401, gin.H{"error": "没有管理员权限"})
+	if attempt.rejectIfThrottled(c) {
 		return
 	}
 
 	// 查找用户
 	var account models.Account
 	if err := database.DB.Where("email = ?", req.Email).First(&account).Error; err != nil {
-		c.JSON(401, gin.H{"error": "用户不存在或密码错误"})
+		failLogin(c, attempt, nil, loginFailUnknownEmail, "用户不存在或密码错误")
+		return
+	}
+
+	// 已停用的用户不能登录
+	if account.Status == models.AccountStatusBanned || account.Status == models.AccountStatusClosed {
+		failLogin(c, attempt, &account, loginFailAccountDisabled, "账号已被停用")
+		return
+	}
+	if account.Status != models.AccountStatusActive {
+		failLogin(c, attempt, &account, loginFailAccountDisabled, "账号未激活")
 		return
 	}
 
 	// 验证密码
 	if account.Password == "" || account.PasswordSalt == "" {
-		c.JSON(401, gin.H{"error": "用户未设置密码"})
+		failLogin(c, attempt, &account, loginFailNoPassword, "用户未设置密码")
+		return
+	}
+	if !passwordMatches(req.Password, account.Password, account.PasswordSalt) {
+		failLogin(c, attempt, &account, loginFailWrongPassword, "用户不存在或密码错误")
 		return
 	}
 
-	// 解码存储的密码和盐值
-	storedPasswordBytes, err := base64.StdEncoding.DecodeString(account.Password)
+	// 验证管理员角色
+	role, err := middleware.ResolveAdminRole(account.ID, account.Email)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "密码解码失败"})
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if role == "" {
+		failLogin(c, attempt, &account, loginFailNotAdmin, "没有管理员权限")
+		return
+	}
+	if err := attempt.succeed(); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	saltBytes, err := base64.StdEncoding.DecodeString(account.PasswordSalt)
+	// 密码超过有效期时需先通过 change_password.json 修改密码
+	if err := syncPasswordHistory(database.DB, account); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	expired, err := passwordExpired(database.DB, account)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "密码盐解码失败"})
+		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
-
-	// 使用相同的盐值加密输入的密码
-	inputPasswordHashed := hashPassword(req.Password, saltBytes)
-
-	// 比较密码
-	if !bytes.Equal([]byte(inputPasswordHashed), storedPasswordBytes) {
-		c.JSON(401, gin.H{"error": "用户不存在或密码错误"})
+	if expired {
+		c.JSON(403, gin.H{"error": "密码已过期，请修改密码", "code": "password_expired"})
 		return
 	}
 
 	// 验证成功后生成 token
-	token, err := utils.GenerateToken(account.ID, account.Email)
+	token, err := utils.GenerateToken(account.ID, account.Email, role)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "生成token失败"})
+		return
+	}
+	refreshToken, err := utils.GenerateRefreshToken(account.ID, account.Email, role)
 	if err != nil {
 		c.JSON(500, gin.H{"error": "生成token失败"})
 		return
 	}
 
 	// 返回用户信息和 token
-	account.Password = ""     // 清除敏感信息
-	account.PasswordSalt = "" // 清除敏感信息
 	c.JSON(200, gin.H{
-		"message": "登录成功",
-		"data":    account,
-		"token":   token,
+		"message":       "登录成功",
+		"data":          models.NewAccountResponse(account),
+		"role":          role,
+		"token":         token,
+		"refresh_token": refreshToken,
 	})
 }
 
+// encodePassword 生成随机盐值并加密密码，返回 base64 编码的密码和盐值，格式与 Dify 一致
+func encodePassword(password string) (string, string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", "", err
+	}
+
+	hashedPassword := []byte(hashPassword(password, salt))
+	return base64.StdEncoding.EncodeToString(hashedPassword), base64.StdEncoding.EncodeToString(salt), nil
+}
+
 // hashPassword 使用 PBKDF2 算法和盐值加密密码
 func hashPassword(password string, salt []byte) string {
 	// 生成 PBKDF2 密钥
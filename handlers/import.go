@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"bytes"
+	"difyserver/database"
+	"difyserver/models"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	maxImportFileSize = 10 << 20 // 导入文件最大 10MB
+	maxImportRows     = 5000     // 单次最多导入的行数
+)
+
+// importColumns 导入文件支持的表头，同时支持中文表头
+var importColumns = map[string]string{
+	"email":     "email",
+	"邮箱":        "email",
+	"name":      "name",
+	"用户名":       "name",
+	"password":  "password",
+	"密码":        "password",
+	"tenant":    "tenant",
+	"tenant_id": "tenant",
+	"工作空间":      "tenant",
+	"role":      "role",
+	"角色":        "role",
+}
+
+// importRow 导入文件中的一行，Line 为文件中的行号（表头为第 1 行）
+type importRow struct {
+	Line     int
+	Email    string
+	Name     string
+	Password string
+	Tenant   string
+	Role     string
+}
+
+// importError 单行的校验错误
+type importError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+// ImportAccounts 从 CSV 或 XLSX 文件批量导入用户及其工作空间角色
+// 同一邮箱可以出现在多行，用于加入多个工作空间；先校验全部数据，有任何错误则不导入
+// dry_run=true 时只校验不写入
+func ImportAccounts(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true" || c.PostForm("dry_run") == "true"
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "请上传导入文件"})
+		return
+	}
+	if fileHeader.Size > maxImportFileSize {
+		c.JSON(400, gin.H{"error": "导入文件不能超过10MB"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".csv":
+		records, err = readCSVRecords(file)
+	case ".xlsx":
+		records, err = readXLSXRecords(file)
+	default:
+		c.JSON(400, gin.H{"error": "只支持 csv 和 xlsx 格式的文件"})
+		return
+	}
+	if err != nil {
+		c.JSON(400, gin.H{"error": "解析导入文件失败: " + err.Error()})
+		return
+	}
+
+	rows, err := parseImportRows(records)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	requests, importErrors := buildImportRequests(c, rows)
+	if len(importErrors) > 0 {
+		c.JSON(400, gin.H{
+			"error":  "导入数据校验失败",
+			"errors": importErrors,
+		})
+		return
+	}
+
+	memberships := 0
+	for _, req := range requests {
+		memberships += len(req.Tenants)
+	}
+	summary := gin.H{
+		"dry_run":     dryRun,
+		"rows":        len(rows),
+		"accounts":    len(requests),
+		"memberships": memberships,
+	}
+
+	if dryRun {
+		summary["message"] = "校验通过"
+		c.JSON(200, summary)
+		return
+	}
+
+	// 开启事务，全部成功才提交
+	tx := database.DB.Begin()
+
+	for _, req := range requests {
+		account, joins, err := req.create(tx)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": fmt.Sprintf("导入用户 %s 失败: %s", req.Email, err.Error())})
+			return
+		}
+
+		after := gin.H{"account": models.NewAccountResponse(account), "tenant_account_joins": joins, "source": "import"}
+		if err := writeAuditLog(c, tx, AuditAddAccount, AuditTargetAccount, account.ID, "", nil, after); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": "写入审计日志失败"})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary["message"] = "导入成功"
+	c.JSON(200, summary)
+}
+
+func readCSVRecords(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// 去掉 Excel 导出 CSV 时带的 UTF-8 BOM
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return reader.ReadAll()
+}
+
+// readXLSXRecords 读取第一个工作表的所有行
+func readXLSXRecords(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("文件中没有工作表")
+	}
+	return f.GetRows(sheets[0])
+}
+
+// parseImportRows 根据表头把记录转换为导入行，跳过空行
+func parseImportRows(records [][]string) ([]importRow, error) {
+	if len(records) == 0 {
+		return nil, errors.New("导入文件为空")
+	}
+
+	index := map[string]int{}
+	for i, header := range records[0] {
+		if column, ok := importColumns[strings.ToLower(strings.TrimSpace(header))]; ok {
+			index[column] = i
+		}
+	}
+	if _, ok := index["email"]; !ok {
+		return nil, errors.New("导入文件缺少 email 列")
+	}
+
+	var rows []importRow
+	for i, record := range records[1:] {
+		value := func(column string) string {
+			if idx, ok := index[column]; ok && idx < len(record) {
+				return strings.TrimSpace(record[idx])
+			}
+			return ""
+		}
+
+		row := importRow{
+			Line:     i + 2,
+			Email:    value("email"),
+			Name:     value("name"),
+			Password: value("password"),
+			Tenant:   value("tenant"),
+			Role:     value("role"),
+		}
+		if row.Email == "" && row.Name == "" && row.Password == "" && row.Tenant == "" && row.Role == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("导入文件中没有数据")
+	}
+	if len(rows) > maxImportRows {
+		return nil, fmt.Errorf("单次最多导入%d行", maxImportRows)
+	}
+	return rows, nil
+}
+
+// buildImportRequests 按邮箱合并导入行并逐个校验，返回所有行的错误
+func buildImportRequests(c *gin.Context, rows []importRow) ([]*createAccountRequest, []importError) {
+	var importErrors []importError
+	var requests []*createAccountRequest
+	byEmail := map[string]*createAccountRequest{}
+	linesByEmail := map[string][]int{}
+	tenantCache := map[string]string{}
+	ownerRows := map[string][]importRow{} // 工作空间ID -> 设为所有者的行
+
+	for _, row := range rows {
+		if row.Email == "" {
+			importErrors = append(importErrors, importError{Line: row.Line, Error: "邮箱不能为空"})
+			continue
+		}
+
+		var tenantID string
+		if row.Tenant != "" {
+			id, err := resolveImportTenant(row.Tenant, tenantCache)
+			if err != nil {
+				importErrors = append(importErrors, importError{Line: row.Line, Email: row.Email, Error: err.Error()})
+				continue
+			}
+			tenantID = id
+		} else if row.Role != "" {
+			importErrors = append(importErrors, importError{Line: row.Line, Email: row.Email, Error: "设置了角色但没有指定工作空间"})
+			continue
+		}
+
+		key := strings.ToLower(row.Email)
+		req, ok := byEmail[key]
+		if !ok {
+			req = &createAccountRequest{Email: row.Email, Name: row.Name, Password: row.Password}
+			byEmail[key] = req
+			requests = append(requests, req)
+		} else if (row.Name != "" && row.Name != req.Name) || (row.Password != "" && row.Password != req.Password) {
+			importErrors = append(importErrors, importError{Line: row.Line, Email: row.Email, Error: "同一邮箱的用户名或密码与之前的行不一致"})
+			continue
+		}
+		linesByEmail[key] = append(linesByEmail[key], row.Line)
+
+		if tenantID != "" {
+			req.Tenants = append(req.Tenants, tenantRole{TenantID: tenantID, Role: row.Role})
+			if row.Role == models.TenantRoleOwner {
+				ownerRows[tenantID] = append(ownerRows[tenantID], row)
+			}
+		}
+	}
+
+	// 按用户校验，错误对应到该用户的所有行
+	for _, req := range requests {
+		if _, err := req.validate(c); err != nil {
+			for _, line := range linesByEmail[strings.ToLower(req.Email)] {
+				importErrors = append(importErrors, importError{Line: line, Email: req.Email, Error: err.Error()})
+			}
+		}
+	}
+
+	// validate 只检查数据库中已有的所有者，同一文件中的多个新用户不能设为同一工作空间的所有者
+	for _, owners := range ownerRows {
+		emails := map[string]bool{}
+		for _, row := range owners {
+			emails[strings.ToLower(row.Email)] = true
+		}
+		if len(emails) < 2 {
+			continue
+		}
+		for _, row := range owners {
+			importErrors = append(importErrors, importError{Line: row.Line, Email: row.Email,
+				Error: fmt.Sprintf("导入文件中有多个用户被设为工作空间 %s 的所有者", row.Tenant)})
+		}
+	}
+	sort.SliceStable(importErrors, func(i, j int) bool { return importErrors[i].Line < importErrors[j].Line })
+
+	return requests, importErrors
+}
+
+// resolveImportTenant 导入文件中的工作空间可以填写ID或名称，名称重复时需要填写ID
+func resolveImportTenant(value string, cache map[string]string) (string, error) {
+	if id, ok := cache[value]; ok {
+		return id, nil
+	}
+
+	var tenants []models.Tenant
+	if err := database.DB.Where("id::text = ? OR name = ?", value, value).Limit(2).Find(&tenants).Error; err != nil {
+		return "", err
+	}
+	if len(tenants) == 0 {
+		return "", fmt.Errorf("工作空间 %s 不存在", value)
+	}
+	if len(tenants) > 1 {
+		return "", fmt.Errorf("存在多个名为 %s 的工作空间，请填写工作空间ID", value)
+	}
+
+	cache[value] = tenants[0].ID
+	return tenants[0].ID, nil
+}
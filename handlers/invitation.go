@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"difyserver/config"
+	"difyserver/database"
+	"difyserver/mailer"
+	"difyserver/models"
+	"difyserver/utils"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errInvalidInvitation 邀请链接无效、已使用、已撤销或已过期
+var errInvalidInvitation = errors.New("邀请链接无效或已过期")
+
+// InviteAccount 邀请新用户：创建状态为 pending 且没有密码的用户，并向其邮箱发送激活链接
+// 参数与 add_account.json 相同，但不能设置密码，由用户打开链接后自行设置
+func InviteAccount(c *gin.Context) {
+	var req createAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Password != "" {
+		c.JSON(400, gin.H{"error": "邀请用户时不能设置密码"})
+		return
+	}
+	if status, err := req.validate(c); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	req.Pending = true
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	account, joins, err := req.create(tx)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenantID := ""
+	if len(joins) > 0 {
+		tenantID = joins[0].TenantID
+	}
+	invitation, err := createInvitation(c, tx, account, tenantID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	after := gin.H{"account": models.NewAccountResponse(account), "tenant_account_joins": joins, "invitation_id": invitation.ID}
+	if err := writeAuditLog(c, tx, AuditInviteAccount, AuditTargetAccount, account.ID, tenantID, nil, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondInvitation(c, account, &invitation)
+}
+
+// ResendInvitation 重新发送邀请，之前未使用的邀请链接全部失效
+func ResendInvitation(c *gin.Context) {
+	var req struct {
+		AccountID string `json:"account_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AccountID == "" {
+		c.JSON(400, gin.H{"error": "account_id 不能为空"})
+		return
+	}
+
+	if !requireAccountInScope(c, req.AccountID) {
+		return
+	}
+
+	var account models.Account
+	if err := database.DB.Where("id = ?", req.AccountID).First(&account).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定用户"})
+		return
+	}
+	if account.Status != models.AccountStatusPending {
+		c.JSON(400, gin.H{"error": "只能邀请未激活的用户"})
+		return
+	}
+
+	// 沿用上一次邀请的工作空间，没有时使用用户的当前工作空间
+	var tenantID string
+	var last models.Invitation
+	if err := database.DB.Where("account_id = ?", account.ID).Order("created_at DESC").First(&last).Error; err == nil {
+		tenantID = last.TenantID
+	} else {
+		var join models.TenantAccountJoin
+		if err := database.DB.Where("account_id = ?", account.ID).Order("current DESC, created_at").First(&join).Error; err == nil {
+			tenantID = join.TenantID
+		}
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	revoked := tx.Model(&models.Invitation{}).
+		Where("account_id = ? AND status = ?", account.ID, models.InvitationStatusPending).
+		Updates(map[string]interface{}{"status": models.InvitationStatusRevoked, "updated_at": time.Now()})
+	if revoked.Error != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": revoked.Error.Error()})
+		return
+	}
+
+	invitation, err := createInvitation(c, tx, account, tenantID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := gin.H{"revoked_invitations": revoked.RowsAffected}
+	after := gin.H{"invitation_id": invitation.ID, "expires_at": invitation.ExpiresAt}
+	if err := writeAuditLog(c, tx, AuditResendInvitation, AuditTargetAccount, account.ID, tenantID, before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondInvitation(c, account, &invitation)
+}
+
+// RevokeInvitation 撤销未使用的邀请，邀请链接随即失效
+func RevokeInvitation(c *gin.Context) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" {
+		c.JSON(400, gin.H{"error": "邀请ID不能为空"})
+		return
+	}
+
+	var invitation models.Invitation
+	if err := database.DB.Where("id = ?", req.ID).First(&invitation).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定邀请"})
+		return
+	}
+	if !requireAccountInScope(c, invitation.AccountID) {
+		return
+	}
+	if invitation.Status != models.InvitationStatusPending {
+		c.JSON(400, gin.H{"error": "只能撤销未使用的邀请"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&models.Invitation{}).Where("id = ?", invitation.ID).
+		Updates(map[string]interface{}{"status": models.InvitationStatusRevoked, "updated_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := gin.H{"status": invitation.Status}
+	after := gin.H{"status": models.InvitationStatusRevoked}
+	if err := writeAuditLog(c, tx, AuditRevokeInvitation, AuditTargetAccount, invitation.AccountID, invitation.TenantID, before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "撤销邀请成功"})
+}
+
+// GetInvitations 查看邀请记录，status=expired 查找已过期未使用的邀请
+func GetInvitations(c *gin.Context) {
+	var invitations []models.Invitation
+	var total int64
+
+	query, err := scopeByAccount(c, database.DB.Model(&models.Invitation{}), "account_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 过期的邀请在数据库中仍为 pending
+	now := time.Now()
+	switch c.Query("status") {
+	case "":
+	case models.InvitationStatusExpired:
+		query = query.Where("status = ? AND expires_at <= ?", models.InvitationStatusPending, now)
+	case models.InvitationStatusPending:
+		query = query.Where("status = ? AND expires_at > ?", models.InvitationStatusPending, now)
+	default:
+		query = query.Where("status = ?", c.Query("status"))
+	}
+
+	query, params, err := parseListQuery(c, query, invitationListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&invitations)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	tenantIDs := make([]string, 0, len(invitations))
+	for _, invitation := range invitations {
+		if invitation.TenantID != "" {
+			tenantIDs = append(tenantIDs, invitation.TenantID)
+		}
+	}
+	tenantNames, err := tenantNameMap(tenantIDs)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	data := make([]models.InvitationResponse, len(invitations))
+	for i, invitation := range invitations {
+		data[i] = models.NewInvitationResponse(invitation)
+		data[i].TenantName = tenantNames[invitation.TenantID]
+	}
+
+	c.JSON(200, params.response(data, total))
+}
+
+// GetInvitation 公开接口，激活页面根据链接中的 token 查询邀请信息
+func GetInvitation(c *gin.Context) {
+	invitation, account, err := loadInvitation(database.DB, c.Query("token"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	info := models.InvitationInfo{
+		Email:     account.Email,
+		Name:      account.Name,
+		ExpiresAt: invitation.ExpiresAt,
+	}
+	if invitation.TenantID != "" {
+		var tenant models.Tenant
+		if err := database.DB.Select("name").Where("id = ?", invitation.TenantID).First(&tenant).Error; err == nil {
+			info.TenantName = tenant.Name
+		}
+	}
+
+	c.JSON(200, info)
+}
+
+// AcceptInvitation 公开接口，被邀请的用户通过激活链接设置自己的密码，用户状态由 pending 改为 active
+// 密码使用与 Dify 相同的 PBKDF2 算法加密，激活后即可登录 Dify
+func AcceptInvitation(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+		Name     string `json:"name"` // 可选，修改用户名称
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Password == "" {
+		c.JSON(400, gin.H{"error": "密码不能为空"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if len([]rune(req.Name)) > 255 {
+		c.JSON(400, gin.H{"error": "用户名不能超过255个字符"})
+		return
+	}
+
+	invitation, account, err := loadInvitation(database.DB, req.Token)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 按密码安全策略检查密码
+	if err := checkPassword(database.DB, req.Password, &account); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	password, salt, err := encodePassword(req.Password)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "生成密码盐失败"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	// 只有仍为 pending 的邀请才能使用，同一链接并发提交时只有一次成功
+	now := time.Now()
+	result := tx.Model(&models.Invitation{}).
+		Where("id = ? AND status = ?", invitation.ID, models.InvitationStatusPending).
+		Updates(map[string]interface{}{"status": models.InvitationStatusAccepted, "accepted_at": now, "updated_at": now})
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected != 1 {
+		tx.Rollback()
+		c.JSON(400, gin.H{"error": errInvalidInvitation.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"password":       password,
+		"password_salt":  salt,
+		"status":         models.AccountStatusActive,
+		"initialized_at": now,
+		"updated_at":     now,
+	}
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	// 用户在邀请之后被停用或已激活时不再修改，邀请保持原状态
+	result = tx.Model(&models.Account{}).
+		Where("id = ? AND status = ?", account.ID, models.AccountStatusPending).
+		Updates(updates)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected != 1 {
+		tx.Rollback()
+		c.JSON(400, gin.H{"error": errInvalidInvitation.Error()})
+		return
+	}
+	if err := recordPasswordHistory(tx, account.ID, password, salt); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 公开接口没有登录用户，操作人记为被邀请的用户本人
+	c.Set("userID", account.ID)
+	c.Set("userEmail", account.Email)
+	before := gin.H{"status": account.Status, "password_set": account.Password != ""}
+	after := gin.H{"status": models.AccountStatusActive, "password_set": true, "invitation_id": invitation.ID}
+	if err := writeAuditLog(c, tx, AuditAcceptInvitation, AuditTargetAccount, account.ID, invitation.TenantID, before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "账号激活成功", "email": account.Email})
+}
+
+// createInvitation 在事务中创建邀请记录
+func createInvitation(c *gin.Context, tx *gorm.DB, account models.Account, tenantID string) (models.Invitation, error) {
+	now := time.Now()
+	invitation := models.Invitation{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		TenantID:  tenantID,
+		Email:     account.Email,
+		InvitedBy: c.GetString("userID"),
+		Status:    models.InvitationStatusPending,
+		ExpiresAt: now.Add(time.Duration(config.GlobalConfig.Invitation.TTL) * time.Hour),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return invitation, tx.Create(&invitation).Error
+}
+
+// loadInvitation 校验邀请链接中的 token，返回仍可使用的邀请和对应的用户
+// 所有失败情况都返回同一个错误，不透露邀请或用户是否存在
+func loadInvitation(db *gorm.DB, token string) (models.Invitation, models.Account, error) {
+	var invitation models.Invitation
+	var account models.Account
+
+	if token == "" {
+		return invitation, account, errInvalidInvitation
+	}
+	claims, err := utils.ParseToken(token)
+	if err != nil || claims.Type != utils.TokenTypeInvitation {
+		return invitation, account, errInvalidInvitation
+	}
+	if err := db.Where("id = ?", claims.Id).First(&invitation).Error; err != nil {
+		return invitation, account, errInvalidInvitation
+	}
+	if invitation.AccountID != claims.ID || invitation.Status != models.InvitationStatusPending ||
+		time.Now().After(invitation.ExpiresAt) {
+		return invitation, account, errInvalidInvitation
+	}
+	if err := db.Where("id = ?", invitation.AccountID).First(&account).Error; err != nil {
+		return invitation, account, errInvalidInvitation
+	}
+	// 邀请期间被停用的用户不能通过链接激活
+	if account.Status != models.AccountStatusPending {
+		return invitation, account, errInvalidInvitation
+	}
+	return invitation, account, nil
+}
+
+// respondInvitation 发送邀请邮件并返回结果，邀请已保存，发送失败时可以重新发送
+func respondInvitation(c *gin.Context, account models.Account, invitation *models.Invitation) {
+	mailErr := sendInvitation(account, invitation)
+	response := gin.H{
+		"account":    models.NewAccountResponse(account),
+		"invitation": models.NewInvitationResponse(*invitation),
+	}
+	if mailErr != nil {
+		log.Println("发送邀请邮件失败:", mailErr)
+		response["mail_error"] = mailErr.Error()
+	}
+	c.JSON(200, response)
+}
+
+// sendInvitation 生成激活链接并发送邮件，发送成功后记录发送时间
+func sendInvitation(account models.Account, invitation *models.Invitation) error {
+	token, err := utils.GenerateInvitationToken(invitation.ID, account.ID, account.Email, invitation.ExpiresAt)
+	if err != nil {
+		return err
+	}
+	link := strings.TrimRight(config.GlobalConfig.Invitation.BaseURL, "/") + "/activate?token=" + url.QueryEscape(token)
+
+	subject := "邀请您加入 Dify"
+	target := "Dify"
+	if invitation.TenantID != "" {
+		var tenant models.Tenant
+		if err := database.DB.Select("name").Where("id = ?", invitation.TenantID).First(&tenant).Error; err == nil {
+			subject = "邀请您加入 Dify 工作空间 " + tenant.Name
+			target = "Dify 工作空间「" + tenant.Name + "」"
+		}
+	}
+	body := fmt.Sprintf("您好，\n\n管理员邀请您加入%s，登录邮箱为 %s。\n请在 %s 之前打开下面的链接设置密码并激活账号：\n\n%s\n\n如果您不知道这个邀请，请忽略这封邮件。\n",
+		target, account.Email, invitation.ExpiresAt.Format("2006-01-02 15:04"), link)
+
+	if err := mailer.Default.Send(mailer.Message{To: account.Email, Subject: subject, Body: body}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	invitation.SentAt = &now
+	return database.DB.Model(&models.Invitation{}).Where("id = ?", invitation.ID).Update("sent_at", now).Error
+}
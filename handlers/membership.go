@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"time"
+)
+
+// membershipColumns 成员关系联表查询返回的字段，对应 models.MembershipResponse
+const membershipColumns = "j.id, j.tenant_id, j.account_id, j.role, j.invited_by, j.current, j.created_at, j.updated_at, " +
+	"a.email AS account_email, a.name AS account_name, a.status AS account_status, " +
+	"t.name AS tenant_name, t.plan AS tenant_plan, t.status AS tenant_status"
+
+// membershipQuery 成员关系联表查询，tenant_account_joins 别名为 j，accounts 为 a，tenants 为 t
+// 统计总数时直接 Count，查询数据时再加上 Select(membershipColumns)
+func membershipQuery() *gorm.DB {
+	return database.DB.Table("tenant_account_joins AS j").
+		Joins("LEFT JOIN accounts a ON a.id = j.account_id").
+		Joins("LEFT JOIN tenants t ON t.id = j.tenant_id")
+}
+
+// GetTenantSummary 工作空间概况：成员总数、各角色成员数和知识库数量
+func GetTenantSummary(c *gin.Context) {
+	tenantID := c.Query("tenant_id")
+	if tenantID == "" {
+		c.JSON(400, gin.H{"error": "tenant_id 参数必填"})
+		return
+	}
+	if !requireTenantInScope(c, tenantID) {
+		return
+	}
+
+	var tenant models.Tenant
+	if err := database.DB.Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return
+	}
+
+	var roles []struct {
+		Role  string
+		Count int64
+	}
+	if err := database.DB.Model(&models.TenantAccountJoin{}).
+		Select("role, COUNT(*) AS count").
+		Where("tenant_id = ?", tenantID).
+		Group("role").
+		Scan(&roles).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary := models.TenantSummaryResponse{
+		Tenant:        models.NewTenantResponse(tenant),
+		MembersByRole: map[string]int64{},
+	}
+	for _, role := range roles {
+		summary.MembersByRole[role.Role] = role.Count
+		summary.MemberCount += role.Count
+	}
+	summary.Tenant.MemberCount = summary.MemberCount
+
+	if err := database.DB.Model(&models.Dataset{}).Where("tenant_id = ?", tenantID).Count(&summary.DatasetCount).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, summary)
+}
+
+// 一致性检查的问题类型
+const (
+	issueTenantWithoutOwner   = "tenant_without_owner"
+	issueTenantMultipleOwners = "tenant_multiple_owners"
+	issueDuplicateMembership  = "duplicate_membership"
+	issueAccountNoCurrent     = "account_without_current_tenant"
+	issueAccountMultiCurrent  = "account_multiple_current_tenants"
+	issueOrphanMembership     = "orphan_membership"
+)
+
+// tenantHasOwner 判断工作空间是否有所有者，exceptAccountID 不为空时不计算该用户
+func tenantHasOwner(db *gorm.DB, tenantID, exceptAccountID string) (bool, error) {
+	query := db.Model(&models.TenantAccountJoin{}).Where("tenant_id = ? AND role = ?", tenantID, models.TenantRoleOwner)
+	if exceptAccountID != "" {
+		query = query.Where("account_id <> ?", exceptAccountID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ensureCurrentTenant 用户加入了工作空间但没有当前工作空间时，把最早加入的工作空间设为当前工作空间
+func ensureCurrentTenant(db *gorm.DB, accountID string) error {
+	var count int64
+	if err := db.Model(&models.TenantAccountJoin{}).
+		Where("account_id = ? AND current = ?", accountID, true).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var join models.TenantAccountJoin
+	err := db.Where("account_id = ?", accountID).Order("created_at, id").First(&join).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.TenantAccountJoin{}).Where("id = ?", join.ID).Update("current", true).Error
+}
+
+// TransferTenantOwner 转移工作空间所有者，新所有者必须已是工作空间成员，原所有者改为 admin
+func TransferTenantOwner(c *gin.Context) {
+	var req struct {
+		TenantID  string `json:"tenant_id"`
+		AccountID string `json:"account_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.TenantID == "" || req.AccountID == "" {
+		c.JSON(400, gin.H{"error": "tenant_id 和 account_id 不能为空"})
+		return
+	}
+
+	if !requireTenantInScope(c, req.TenantID) {
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var join models.TenantAccountJoin
+	if err := tx.Where("tenant_id = ? AND account_id = ?", req.TenantID, req.AccountID).First(&join).Error; err != nil {
+		tx.Rollback()
+		c.JSON(400, gin.H{"error": "新所有者必须是工作空间成员"})
+		return
+	}
+	if join.Role == models.TenantRoleOwner {
+		tx.Rollback()
+		c.JSON(400, gin.H{"error": "该用户已是工作空间所有者"})
+		return
+	}
+
+	var owners []models.TenantAccountJoin
+	if err := tx.Where("tenant_id = ? AND role = ?", req.TenantID, models.TenantRoleOwner).Find(&owners).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	if err := tx.Model(&models.TenantAccountJoin{}).
+		Where("tenant_id = ? AND role = ?", req.TenantID, models.TenantRoleOwner).
+		Updates(map[string]interface{}{"role": models.TenantRoleAdmin, "updated_at": now}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Model(&models.TenantAccountJoin{}).
+		Where("id = ?", join.ID).
+		Updates(map[string]interface{}{"role": models.TenantRoleOwner, "updated_at": now}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	previous := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		previous = append(previous, owner.AccountID)
+	}
+	before := gin.H{"owners": previous, "role": join.Role}
+	after := gin.H{"owner": req.AccountID}
+	if err := writeAuditLog(c, tx, AuditTransferTenantOwner, AuditTargetTenant, req.TenantID, req.TenantID, before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "转移所有者成功"})
+}
+
+// CheckTenantConsistency 检查数据库中已有的成员关系是否满足约束：
+// 每个工作空间有且只有一个所有者、用户与工作空间的关联不重复、每个用户有且只有一个当前工作空间、关联的用户和工作空间存在
+func CheckTenantConsistency(c *gin.Context) {
+	report := models.ConsistencyReport{Issues: []models.ConsistencyIssue{}}
+
+	// 没有所有者的工作空间
+	var tenantIDs []string
+	query, err := scopeByTenant(c, database.DB.Model(&models.Tenant{}), "id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.
+		Where("NOT EXISTS (SELECT 1 FROM tenant_account_joins j WHERE j.tenant_id = tenants.id AND j.role = ?)", models.TenantRoleOwner).
+		Order("created_at").
+		Pluck("id", &tenantIDs).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, id := range tenantIDs {
+		report.Issues = append(report.Issues, models.ConsistencyIssue{
+			Type: issueTenantWithoutOwner, TenantID: id, Message: "工作空间没有所有者",
+		})
+	}
+
+	var groups []struct {
+		TenantID  string
+		AccountID string
+		Count     int64
+	}
+
+	// 有多个所有者的工作空间
+	query, err = scopeByTenant(c, database.DB.Model(&models.TenantAccountJoin{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Select("tenant_id, COUNT(*) AS count").
+		Where("role = ?", models.TenantRoleOwner).
+		Group("tenant_id").Having("COUNT(*) > 1").
+		Scan(&groups).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, g := range groups {
+		report.Issues = append(report.Issues, models.ConsistencyIssue{
+			Type: issueTenantMultipleOwners, TenantID: g.TenantID, Count: g.Count, Message: "工作空间有多个所有者",
+		})
+	}
+
+	// 重复的关联关系
+	groups = nil
+	query, err = scopeByTenant(c, database.DB.Model(&models.TenantAccountJoin{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Select("tenant_id, account_id, COUNT(*) AS count").
+		Group("tenant_id, account_id").Having("COUNT(*) > 1").
+		Scan(&groups).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, g := range groups {
+		report.Issues = append(report.Issues, models.ConsistencyIssue{
+			Type: issueDuplicateMembership, TenantID: g.TenantID, AccountID: g.AccountID, Count: g.Count, Message: "用户在工作空间中有重复的关联关系",
+		})
+	}
+
+	// 当前工作空间不是恰好一个的用户
+	groups = nil
+	query, err = scopeByAccount(c, database.DB.Model(&models.TenantAccountJoin{}), "account_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Select("account_id, SUM(CASE WHEN current THEN 1 ELSE 0 END) AS count").
+		Group("account_id").Having("SUM(CASE WHEN current THEN 1 ELSE 0 END) <> 1").
+		Scan(&groups).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, g := range groups {
+		issue := models.ConsistencyIssue{Type: issueAccountNoCurrent, AccountID: g.AccountID, Count: g.Count, Message: "用户没有当前工作空间"}
+		if g.Count > 1 {
+			issue.Type = issueAccountMultiCurrent
+			issue.Message = "用户有多个当前工作空间"
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// 用户或工作空间已不存在的关联关系
+	groups = nil
+	query, err = scopeByTenant(c, membershipQuery(), "j.tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := query.Select("j.tenant_id, j.account_id, COUNT(*) AS count").
+		Where("a.id IS NULL OR t.id IS NULL").
+		Group("j.tenant_id, j.account_id").
+		Scan(&groups).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	for _, g := range groups {
+		report.Issues = append(report.Issues, models.ConsistencyIssue{
+			Type: issueOrphanMembership, TenantID: g.TenantID, AccountID: g.AccountID, Count: g.Count, Message: "关联的用户或工作空间不存在",
+		})
+	}
+
+	report.Total = len(report.Issues)
+	c.JSON(200, report)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"difyserver/models"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// tableMover 在事务中执行迁移语句，记录每张表修改的行数以及当前 Dify 版本中不存在的表
+type tableMover struct {
+	tx      *gorm.DB
+	changes []models.TableChange
+	skipped []string
+}
+
+func newTableMover(tx *gorm.DB) *tableMover {
+	return &tableMover{tx: tx, changes: []models.TableChange{}, skipped: []string{}}
+}
+
+// hasTable 判断表是否存在，不存在时记录到 skipped
+// 需要在执行语句前检查，PostgreSQL 事务中的语句出错后整个事务都不可用
+func (m *tableMover) hasTable(table string) bool {
+	if m.tx.Migrator().HasTable(table) {
+		return true
+	}
+	m.skipped = append(m.skipped, table)
+	return false
+}
+
+// exec 执行一条修改语句并记录影响的行数
+func (m *tableMover) exec(table, action, note, sql string, args ...interface{}) error {
+	result := m.tx.Exec(sql, args...)
+	if result.Error != nil {
+		return fmt.Errorf("修改 %s 失败: %w", table, result.Error)
+	}
+	m.changes = append(m.changes, models.TableChange{
+		Table: table, Action: action, Rows: result.RowsAffected, Note: note,
+	})
+	return nil
+}
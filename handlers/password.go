@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"bytes"
+	"difyserver/config"
+	"difyserver/database"
+	"difyserver/middleware"
+	"difyserver/models"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// commonPasswords 内置的常见弱密码，与配置中的 denylist 一起检查，不区分大小写
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "1234567", "12345", "111111", "000000", "123123",
+	"654321", "666666", "888888", "112233", "121212", "123321", "147258369", "1q2w3e4r", "1qaz2wsx",
+	"a123456", "a12345678", "abc123", "abc12345", "abcd1234", "admin", "admin123", "admin1234", "admin888",
+	"dify", "dify123", "dify1234", "iloveyou", "letmein", "monkey", "p@ssw0rd", "passw0rd", "password",
+	"password1", "password123", "qazwsx", "qwe123", "qwer1234", "qwerty", "qwerty123", "root", "root123",
+	"test", "test123", "test1234", "welcome", "welcome1", "woaini1314", "zxcvbnm",
+}
+
+// passwordPolicyError 密码不满足安全策略，Violations 列出所有不满足的规则
+type passwordPolicyError struct {
+	Violations []models.PasswordViolation
+}
+
+func (e *passwordPolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "密码不符合安全策略：" + strings.Join(messages, "；")
+}
+
+// passwordErrorResponse 生成错误响应，密码不满足安全策略时同时返回不满足的规则
+func passwordErrorResponse(err error) gin.H {
+	var policyErr *passwordPolicyError
+	if errors.As(err, &policyErr) {
+		return gin.H{"error": err.Error(), "violations": policyErr.Violations}
+	}
+	return gin.H{"error": err.Error()}
+}
+
+// respondPasswordError 密码不满足安全策略时返回 400 及不满足的规则，其他错误返回 500
+func respondPasswordError(c *gin.Context, err error) {
+	var policyErr *passwordPolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(400, passwordErrorResponse(err))
+		return
+	}
+	c.JSON(500, gin.H{"error": err.Error()})
+}
+
+// checkPassword 按 password_policy 检查密码，返回 *passwordPolicyError 列出所有不满足的规则
+// account 不为空时同时检查是否与该用户最近使用过的密码相同
+func checkPassword(db *gorm.DB, password string, account *models.Account) error {
+	policy := config.GlobalConfig.PasswordPolicy
+	var violations []models.PasswordViolation
+	add := func(rule, message string) {
+		violations = append(violations, models.PasswordViolation{Rule: rule, Message: message})
+	}
+
+	if len([]rune(password)) < policy.MinLength {
+		add("min_length", fmt.Sprintf("密码长度至少%d位", policy.MinLength))
+	}
+
+	var hasLetter, hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasLetter, hasUpper = true, true
+		case unicode.IsLower(r):
+			hasLetter, hasLower = true, true
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' ':
+			hasSymbol = true
+		}
+	}
+	if policy.RequireLetter && !hasLetter {
+		add("require_letter", "密码必须包含字母")
+	}
+	if policy.RequireUpper && !hasUpper {
+		add("require_upper", "密码必须包含大写字母")
+	}
+	if policy.RequireLower && !hasLower {
+		add("require_lower", "密码必须包含小写字母")
+	}
+	if policy.RequireDigit && !hasDigit {
+		add("require_digit", "密码必须包含数字")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		add("require_symbol", "密码必须包含特殊字符")
+	}
+
+	if passwordDenied(password, policy.Denylist) {
+		add("denylist", "密码过于常见，请使用其他密码")
+	}
+
+	if account != nil && policy.History > 0 {
+		reused, err := passwordReused(db, account, password, policy.History)
+		if err != nil {
+			return err
+		}
+		if reused {
+			add("history", fmt.Sprintf("不能使用最近%d次使用过的密码", policy.History))
+		}
+	}
+
+	if len(violations) > 0 {
+		return &passwordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// passwordDenied 判断密码是否在内置或配置的黑名单中
+func passwordDenied(password string, denylist []string) bool {
+	for _, lists := range [][]string{commonPasswords, denylist} {
+		for _, denied := range lists {
+			if strings.EqualFold(password, denied) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// passwordReused 判断密码是否与用户当前密码或最近 n 次通过 DifyServer 设置的密码相同
+func passwordReused(db *gorm.DB, account *models.Account, password string, n int) (bool, error) {
+	if passwordMatches(password, account.Password, account.PasswordSalt) {
+		return true, nil
+	}
+
+	var histories []models.PasswordHistory
+	if err := db.Where("account_id = ?", account.ID).Order("created_at DESC").Limit(n).Find(&histories).Error; err != nil {
+		return false, err
+	}
+	for _, history := range histories {
+		if passwordMatches(password, history.Password, history.PasswordSalt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// passwordMatches 判断密码与 base64 编码的密码和盐值是否一致，格式与 Dify 相同
+func passwordMatches(password, encoded, salt string) bool {
+	if encoded == "" || salt == "" {
+		return false
+	}
+	storedPassword, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal([]byte(hashPassword(password, saltBytes)), storedPassword)
+}
+
+// recordPasswordHistory 在事务中记录新设置的密码，只保留密码有效期和重复检查需要的最近记录
+func recordPasswordHistory(tx *gorm.DB, accountID, password, salt string) error {
+	history := models.PasswordHistory{
+		ID:           uuid.New().String(),
+		AccountID:    accountID,
+		Password:     password,
+		PasswordSalt: salt,
+		CreatedAt:    time.Now(),
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return err
+	}
+
+	keep := config.GlobalConfig.PasswordPolicy.History
+	if keep < 1 {
+		keep = 1 // 至少保留最后一次，用于计算密码有效期
+	}
+	return tx.Where("account_id = ? AND id NOT IN (?)", accountID,
+		tx.Model(&models.PasswordHistory{}).Select("id").
+			Where("account_id = ?", accountID).Order("created_at DESC").Limit(keep)).
+		Delete(&models.PasswordHistory{}).Error
+}
+
+// passwordChangedAt 用户当前密码的设置时间，取最近一条密码历史
+// 没有历史记录，或最近一条记录与当前密码不同（在 Dify 中修改过密码）时无法确定，known 为 false
+func passwordChangedAt(db *gorm.DB, account models.Account) (changedAt time.Time, known bool, err error) {
+	var history models.PasswordHistory
+	err = db.Where("account_id = ?", account.ID).Order("created_at DESC").First(&history).Error
+	if err == gorm.ErrRecordNotFound {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if history.Password != account.Password {
+		return time.Time{}, false, nil
+	}
+	return history.CreatedAt, true, nil
+}
+
+// syncPasswordHistory 登录成功时调用，无法确定当前密码的设置时间时以本次登录时间记录一条密码历史，
+// 密码有效期从此时开始计算，避免启用 max_age_days 后较早创建的管理员全部无法登录
+func syncPasswordHistory(db *gorm.DB, account models.Account) error {
+	_, known, err := passwordChangedAt(db, account)
+	if err != nil || known {
+		return err
+	}
+
+	// 开启事务
+	tx := db.Begin()
+	if err := recordPasswordHistory(tx, account.ID, account.Password, account.PasswordSalt); err != nil {
+		tx.Rollback()
+		return err
+	}
+	// 提交事务
+	return tx.Commit().Error
+}
+
+// passwordExpired 判断用户的密码是否超过 max_age_days，无法确定设置时间时视为未过期
+func passwordExpired(db *gorm.DB, account models.Account) (bool, error) {
+	maxAge := config.GlobalConfig.PasswordPolicy.MaxAgeDays
+	if maxAge <= 0 {
+		return false, nil
+	}
+	changedAt, known, err := passwordChangedAt(db, account)
+	if err != nil || !known {
+		return false, err
+	}
+	return time.Since(changedAt) > time.Duration(maxAge)*24*time.Hour, nil
+}
+
+// ChangePassword 公开接口，管理员使用当前密码修改自己的密码，密码过期后无法登录时也可以使用
+func ChangePassword(c *gin.Context) {
+	var req struct {
+		Email       string `json:"email"`
+		Password    string `json:"password"`     // 当前密码
+		NewPassword string `json:"new_password"` // 新密码
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Email == "" || req.Password == "" || req.NewPassword == "" {
+		c.JSON(400, gin.H{"error": "邮箱、当前密码和新密码不能为空"})
+		return
+	}
+
+	// 与登录共用失败次数限制，避免通过该接口猜测密码
+	attempt, err := beginLoginAttempt(c, req.Email)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if attempt.rejectIfThrottled(c) {
+		return
+	}
+
+	// 与登录相同，用户不存在和密码错误返回同样的错误
+	var account models.Account
+	if err := database.DB.Where("email = ?", req.Email).First(&account).Error; err != nil {
+		failLogin(c, attempt, nil, loginFailUnknownEmail, "用户不存在或密码错误")
+		return
+	}
+	if account.Status == models.AccountStatusBanned || account.Status == models.AccountStatusClosed {
+		failLogin(c, attempt, &account, loginFailAccountDisabled, "账号已被停用")
+		return
+	}
+	if !passwordMatches(req.Password, account.Password, account.PasswordSalt) {
+		failLogin(c, attempt, &account, loginFailWrongPassword, "用户不存在或密码错误")
+		return
+	}
+
+	// 与登录相同，只有管理员可以通过 DifyServer 修改自己的密码
+	role, err := middleware.ResolveAdminRole(account.ID, account.Email)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if role == "" {
+		failLogin(c, attempt, &account, loginFailNotAdmin, "没有管理员权限")
+		return
+	}
+	if err := attempt.succeed(); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := checkPassword(database.DB, req.NewPassword, &account); err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	password, salt, err := encodePassword(req.NewPassword)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "生成密码盐失败"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if err := tx.Model(&models.Account{}).Where("id = ?", account.ID).
+		Updates(map[string]interface{}{"password": password, "password_salt": salt, "updated_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "修改密码失败"})
+		return
+	}
+	if err := recordPasswordHistory(tx, account.ID, password, salt); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 修改密码后其他已登录的会话全部失效
+	if err := revokeAccountTokens(tx, account.ID, "password_change"); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 公开接口没有登录用户，操作人记为用户本人
+	c.Set("userID", account.ID)
+	c.Set("userEmail", account.Email)
+	if err := writeAuditLog(c, tx, AuditChangePassword, AuditTargetAccount, account.ID, "", nil, gin.H{"password_set": true}); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": "修改密码失败"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "修改密码成功"})
+}
+
+// GetExpiredPasswords 查看密码超过 max_age_days 的用户，未配置密码有效期时返回空列表
+// Dify 不记录密码修改时间，只统计通过 DifyServer 设置的密码，没有记录的用户按创建时间计算
+func GetExpiredPasswords(c *gin.Context) {
+	var rows []models.PasswordExpiryResponse
+	var total int64
+
+	// 只统计最近一条密码历史与当前密码相同的用户，无法确定密码设置时间的用户视为未过期
+	query := database.DB.Table("accounts AS a").
+		Joins("JOIN (SELECT DISTINCT ON (account_id) account_id, password, created_at AS changed_at FROM difyserver_password_histories ORDER BY account_id, created_at DESC) h ON h.account_id = a.id AND h.password = a.password").
+		Where("a.password IS NOT NULL AND a.password <> ''")
+
+	maxAge := config.GlobalConfig.PasswordPolicy.MaxAgeDays
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-time.Duration(maxAge) * 24 * time.Hour)
+		query = query.Where("h.changed_at < ?", cutoff)
+	} else {
+		query = query.Where("1 = 0")
+	}
+
+	query, err := scopeByAccount(c, query, "a.id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, passwordExpiryListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).
+		Select("a.id, a.email, a.name, a.status, h.changed_at AS password_changed_at").
+		Scan(&rows)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+	for i := range rows {
+		rows[i].ExpiredAt = rows[i].PasswordChangedAt.Add(time.Duration(maxAge) * 24 * time.Hour)
+	}
+
+	c.JSON(200, params.response(rows, total))
+}
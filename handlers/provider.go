@@ -0,0 +1,569 @@
+package handlers
+
+import (
+	"crypto/rsa"
+	"difyserver/database"
+	"difyserver/models"
+	"difyserver/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// credentialKeys 按工作空间缓存私钥，读取失败的工作空间缓存为 nil，用于列表中批量脱敏凭据
+type credentialKeys map[string]*rsa.PrivateKey
+
+func (k credentialKeys) get(tenantID string) *rsa.PrivateKey {
+	key, ok := k[tenantID]
+	if !ok {
+		key, _ = utils.LoadPrivateKey(tenantID)
+		k[tenantID] = key
+	}
+	return key
+}
+
+// maskCredentials 解析 encrypted_config 并把其中加密的字段解密后脱敏
+// 未加密的字段（如接口地址）原样返回，无法解密的字段全部替换为 *，任何情况下都不会返回完整的密钥
+func maskCredentials(config *string, key *rsa.PrivateKey) map[string]interface{} {
+	if config == nil || *config == "" {
+		return nil
+	}
+	var credentials map[string]interface{}
+	if err := json.Unmarshal([]byte(*config), &credentials); err != nil {
+		return nil
+	}
+	for name, value := range credentials {
+		s, ok := value.(string)
+		if !ok || !utils.IsEncryptedToken(s) {
+			continue
+		}
+		credentials[name] = strings.Repeat("*", 20)
+		if key == nil {
+			continue
+		}
+		if plaintext, err := utils.DecryptToken(key, s); err == nil {
+			credentials[name] = utils.ObfuscatedToken(plaintext)
+		}
+	}
+	return credentials
+}
+
+// reencryptCredentials 用源工作空间的私钥解密凭据中加密的字段，再用目标工作空间的公钥重新加密
+func reencryptCredentials(config *string, source *rsa.PrivateKey, target *rsa.PublicKey) (*string, error) {
+	if config == nil || *config == "" {
+		return config, nil
+	}
+	var credentials map[string]interface{}
+	if err := json.Unmarshal([]byte(*config), &credentials); err != nil {
+		return nil, errors.New("凭据格式错误")
+	}
+	for name, value := range credentials {
+		s, ok := value.(string)
+		if !ok || !utils.IsEncryptedToken(s) {
+			continue
+		}
+		plaintext, err := utils.DecryptToken(source, s)
+		if err != nil {
+			return nil, fmt.Errorf("解密凭据字段 %s 失败", name)
+		}
+		if credentials[name], err = utils.EncryptToken(target, plaintext); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(credentials)
+	if err != nil {
+		return nil, err
+	}
+	result := string(data)
+	return &result, nil
+}
+
+// GetProviders 跨工作空间查看模型供应商，凭据中加密的字段已脱敏
+func GetProviders(c *gin.Context) {
+	var providers []models.Provider
+	var total int64
+
+	query, err := scopeByTenant(c, database.DB.Model(&models.Provider{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, providerListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&providers)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	tenantIDs := make([]string, len(providers))
+	for i, provider := range providers {
+		tenantIDs[i] = provider.TenantID
+	}
+	tenantNames, err := tenantNameMap(tenantIDs)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys := credentialKeys{}
+	data := make([]models.ProviderResponse, len(providers))
+	for i, provider := range providers {
+		data[i] = models.NewProviderResponse(provider)
+		data[i].TenantName = tenantNames[provider.TenantID]
+		data[i].Credentials = maskCredentials(provider.EncryptedConfig, keys.get(provider.TenantID))
+	}
+
+	c.JSON(200, params.response(data, total))
+}
+
+// GetProviderModels 跨工作空间查看单独配置了凭据的模型，凭据中加密的字段已脱敏
+func GetProviderModels(c *gin.Context) {
+	var providerModels []models.ProviderModel
+	var total int64
+
+	query, err := scopeByTenant(c, database.DB.Model(&models.ProviderModel{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, providerModelListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&providerModels)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	tenantIDs := make([]string, len(providerModels))
+	for i, model := range providerModels {
+		tenantIDs[i] = model.TenantID
+	}
+	tenantNames, err := tenantNameMap(tenantIDs)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	keys := credentialKeys{}
+	data := make([]models.ProviderModelResponse, len(providerModels))
+	for i, model := range providerModels {
+		data[i] = models.NewProviderModelResponse(model)
+		data[i].TenantName = tenantNames[model.TenantID]
+		data[i].Credentials = maskCredentials(model.EncryptedConfig, keys.get(model.TenantID))
+	}
+
+	c.JSON(200, params.response(data, total))
+}
+
+// GetTenantDefaultModels 查看工作空间的默认模型
+func GetTenantDefaultModels(c *gin.Context) {
+	var defaults []models.TenantDefaultModel
+	var total int64
+
+	query, err := scopeByTenant(c, database.DB.Model(&models.TenantDefaultModel{}), "tenant_id")
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	query, params, err := parseListQuery(c, query, tenantDefaultModelListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&defaults)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(200, params.response(defaults, total))
+}
+
+// SetTenantDefaultModel 设置工作空间某一类型的默认模型，模型的供应商必须已在该工作空间中配置
+// model_type 可以是 llm、text-embedding 等接口中的类型，也可以是 Dify 数据库中保存的类型
+func SetTenantDefaultModel(c *gin.Context) {
+	var req struct {
+		TenantID     string `json:"tenant_id"`
+		ModelType    string `json:"model_type"`
+		ProviderName string `json:"provider_name"`
+		ModelName    string `json:"model_name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.TenantID == "" || req.ProviderName == "" || req.ModelName == "" {
+		c.JSON(400, gin.H{"error": "tenant_id、provider_name 和 model_name 不能为空"})
+		return
+	}
+	modelType, ok := originModelType(req.ModelType)
+	if !ok {
+		c.JSON(400, gin.H{"error": "不支持的模型类型 " + req.ModelType})
+		return
+	}
+
+	if !requireTenantInScope(c, req.TenantID) {
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Tenant{}).Where("id = ?", req.TenantID).Count(&count).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if count == 0 {
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return
+	}
+
+	configured, err := providerConfigured(database.DB, req.TenantID, req.ProviderName, req.ModelName)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !configured {
+		c.JSON(400, gin.H{"error": "工作空间没有配置模型供应商 " + req.ProviderName})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	now := time.Now()
+	var before interface{}
+	var model models.TenantDefaultModel
+	err = tx.Where("tenant_id = ? AND model_type = ?", req.TenantID, modelType).First(&model).Error
+	if err == gorm.ErrRecordNotFound {
+		model = models.TenantDefaultModel{
+			ID:           uuid.New().String(),
+			TenantID:     req.TenantID,
+			ProviderName: req.ProviderName,
+			ModelName:    req.ModelName,
+			ModelType:    modelType,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		err = tx.Create(&model).Error
+	} else if err == nil {
+		before = gin.H{"provider_name": model.ProviderName, "model_name": model.ModelName}
+		model.ProviderName = req.ProviderName
+		model.ModelName = req.ModelName
+		model.UpdatedAt = now
+		err = tx.Model(&models.TenantDefaultModel{}).Where("id = ?", model.ID).
+			Updates(map[string]interface{}{"provider_name": req.ProviderName, "model_name": req.ModelName, "updated_at": now}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	after := gin.H{"model_type": modelType, "provider_name": req.ProviderName, "model_name": req.ModelName}
+	if err := writeAuditLog(c, tx, AuditSetTenantDefaultModel, AuditTargetTenant, req.TenantID, req.TenantID, before, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, model)
+}
+
+// CopyProvider 把一个工作空间的自定义供应商配置复制到另一个工作空间
+// 复制 providers 中的自定义凭据、provider_models 中的模型凭据和供应商偏好，凭据用源工作空间私钥解密后再用目标工作空间公钥加密
+// 目标工作空间已配置该供应商时返回 409，overwrite=true 时删除原有配置后重新创建，新记录使用新 ID，Dify 不会读到缓存的旧凭据
+func CopyProvider(c *gin.Context) {
+	var req struct {
+		SourceTenantID string `json:"source_tenant_id"`
+		TargetTenantID string `json:"target_tenant_id"`
+		ProviderName   string `json:"provider_name"`
+		Overwrite      bool   `json:"overwrite"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.SourceTenantID == "" || req.TargetTenantID == "" || req.ProviderName == "" {
+		c.JSON(400, gin.H{"error": "source_tenant_id、target_tenant_id 和 provider_name 不能为空"})
+		return
+	}
+	if req.SourceTenantID == req.TargetTenantID {
+		c.JSON(400, gin.H{"error": "源工作空间和目标工作空间不能相同"})
+		return
+	}
+
+	if !requireTenantInScope(c, req.SourceTenantID) || !requireTenantInScope(c, req.TargetTenantID) {
+		return
+	}
+
+	var target models.Tenant
+	if err := database.DB.Where("id = ?", req.TargetTenantID).First(&target).Error; err != nil {
+		c.JSON(404, gin.H{"error": "未找到目标工作空间"})
+		return
+	}
+	publicKey, err := utils.ParsePublicKey(target.EncryptPublicKey)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "目标工作空间的公钥无效: " + err.Error()})
+		return
+	}
+
+	names := providerNameVariants(req.ProviderName)
+
+	config, err := loadProviderConfig(database.DB, req.SourceTenantID, names)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if len(config.Providers) == 0 && len(config.Models) == 0 {
+		c.JSON(404, gin.H{"error": "源工作空间没有配置供应商 " + req.ProviderName})
+		return
+	}
+
+	privateKey, err := utils.LoadPrivateKey(req.SourceTenantID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 在事务外完成加解密，失败时不需要回滚
+	if err := config.retarget(req.TargetTenantID, privateKey, publicKey, time.Now()); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var existingProviders, existingModels int64
+	if err := tx.Model(&models.Provider{}).
+		Where("tenant_id = ? AND provider_name IN ? AND provider_type = ?", req.TargetTenantID, names, models.ProviderTypeCustom).
+		Count(&existingProviders).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if err := tx.Model(&models.ProviderModel{}).
+		Where("tenant_id = ? AND provider_name IN ?", req.TargetTenantID, names).
+		Count(&existingModels).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	replaced := existingProviders > 0 || existingModels > 0
+	if replaced && !req.Overwrite {
+		tx.Rollback()
+		c.JSON(409, gin.H{"error": "目标工作空间已配置该供应商，如需覆盖请设置 overwrite"})
+		return
+	}
+
+	if replaced {
+		if err := tx.Where("tenant_id = ? AND provider_name IN ? AND provider_type = ?", req.TargetTenantID, names, models.ProviderTypeCustom).
+			Delete(&models.Provider{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Where("tenant_id = ? AND provider_name IN ?", req.TargetTenantID, names).
+			Delete(&models.ProviderModel{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if len(config.Preferred) > 0 {
+		if err := tx.Where("tenant_id = ? AND provider_name IN ?", req.TargetTenantID, names).
+			Delete(&models.TenantPreferredModelProvider{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := config.create(tx); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := models.ProviderCopyReport{
+		ProviderName:   req.ProviderName,
+		SourceTenantID: req.SourceTenantID,
+		TargetTenantID: req.TargetTenantID,
+		Providers:      len(config.Providers),
+		ProviderModels: len(config.Models),
+		Preferred:      len(config.Preferred) > 0,
+		Replaced:       replaced,
+	}
+
+	var before interface{}
+	if replaced {
+		before = gin.H{"providers": existingProviders, "provider_models": existingModels}
+	}
+	if err := writeAuditLog(c, tx, AuditCopyProvider, AuditTargetProvider, req.ProviderName, req.TargetTenantID, before, report); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, report)
+}
+
+// originModelType 把接口中的模型类型转换为 Dify 数据库中保存的类型
+func originModelType(modelType string) (string, bool) {
+	if origin, ok := models.ModelTypes[modelType]; ok {
+		return origin, true
+	}
+	for _, origin := range models.ModelTypes {
+		if origin == modelType {
+			return origin, true
+		}
+	}
+	return "", false
+}
+
+// providerConfigured 判断工作空间是否配置了可用的供应商，或单独配置了该模型的凭据
+func providerConfigured(db *gorm.DB, tenantID, providerName, modelName string) (bool, error) {
+	names := providerNameVariants(providerName)
+
+	var count int64
+	if err := db.Model(&models.Provider{}).
+		Where("tenant_id = ? AND provider_name IN ? AND is_valid = ?", tenantID, names, true).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := db.Model(&models.ProviderModel{}).
+		Where("tenant_id = ? AND provider_name IN ? AND model_name = ? AND is_valid = ?", tenantID, names, modelName, true).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// providerConfig 工作空间中供应商的自定义凭据、模型凭据和偏好设置
+type providerConfig struct {
+	Providers []models.Provider
+	Models    []models.ProviderModel
+	Preferred []models.TenantPreferredModelProvider
+}
+
+// loadProviderConfig 读取工作空间的供应商配置，names 为空时读取全部供应商
+// 系统供应商（托管额度）由 Dify 按需创建，不需要复制
+func loadProviderConfig(db *gorm.DB, tenantID string, names []string) (*providerConfig, error) {
+	filter := func() *gorm.DB {
+		query := db.Where("tenant_id = ?", tenantID)
+		if len(names) > 0 {
+			query = query.Where("provider_name IN ?", names)
+		}
+		return query
+	}
+
+	config := &providerConfig{}
+	if err := filter().Where("provider_type = ?", models.ProviderTypeCustom).Find(&config.Providers).Error; err != nil {
+		return nil, err
+	}
+	if err := filter().Find(&config.Models).Error; err != nil {
+		return nil, err
+	}
+	if err := filter().Find(&config.Preferred).Error; err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// retarget 把配置改为属于目标工作空间：使用新 ID，凭据用源工作空间私钥解密后再用目标工作空间公钥加密
+func (p *providerConfig) retarget(tenantID string, source *rsa.PrivateKey, target *rsa.PublicKey, now time.Time) error {
+	for i := range p.Providers {
+		config, err := reencryptCredentials(p.Providers[i].EncryptedConfig, source, target)
+		if err != nil {
+			return errors.New("供应商 " + p.Providers[i].ProviderName + " 的" + err.Error())
+		}
+		p.Providers[i].ID = uuid.New().String()
+		p.Providers[i].TenantID = tenantID
+		p.Providers[i].EncryptedConfig = config
+		p.Providers[i].LastUsed = nil
+		p.Providers[i].CreatedAt = now
+		p.Providers[i].UpdatedAt = now
+	}
+	for i := range p.Models {
+		config, err := reencryptCredentials(p.Models[i].EncryptedConfig, source, target)
+		if err != nil {
+			return errors.New("模型 " + p.Models[i].ModelName + " 的" + err.Error())
+		}
+		p.Models[i].ID = uuid.New().String()
+		p.Models[i].TenantID = tenantID
+		p.Models[i].EncryptedConfig = config
+		p.Models[i].CreatedAt = now
+		p.Models[i].UpdatedAt = now
+	}
+	for i := range p.Preferred {
+		p.Preferred[i].ID = uuid.New().String()
+		p.Preferred[i].TenantID = tenantID
+		p.Preferred[i].CreatedAt = now
+		p.Preferred[i].UpdatedAt = now
+	}
+	return nil
+}
+
+// create 在事务中写入配置
+func (p *providerConfig) create(tx *gorm.DB) error {
+	if len(p.Providers) > 0 {
+		if err := tx.Create(&p.Providers).Error; err != nil {
+			return err
+		}
+	}
+	if len(p.Models) > 0 {
+		if err := tx.Create(&p.Models).Error; err != nil {
+			return err
+		}
+	}
+	if len(p.Preferred) > 0 {
+		if err := tx.Create(&p.Preferred).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
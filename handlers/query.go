@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"difyserver/models"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+// listOptions 列表接口允许的搜索、筛选和排序字段，只有白名单中的字段才会拼接到 SQL 中
+type listOptions struct {
+	Search        func(query *gorm.DB, pattern string) *gorm.DB // search 参数的匹配方式，pattern 已包含通配符
+	Filters       map[string]listFilter                         // 查询参数名 -> 筛选字段，精确匹配，多个值用逗号分隔
+	SortColumns   map[string]string                             // sort 参数值 -> 字段名
+	DefaultSort   string                                        // 默认排序字段，为 SortColumns 中的键
+	DefaultOrder  string                                        // 默认排序方向，为空时为 desc
+	CreatedColumn string                                        // created_from、created_to 筛选的字段
+	IDColumn      string                                        // 排序字段相同时按该字段排序，保证分页稳定
+}
+
+// filterType 筛选参数的类型，布尔和 UUID 类型的值在拼接查询前校验，
+// 格式错误时返回 400，而不是由数据库报错
+type filterType int
+
+const (
+	filterText filterType = iota
+	filterBool
+	filterUUID
+)
+
+// listFilter 筛选参数对应的字段及其类型
+type listFilter struct {
+	Column string
+	Type   filterType
+}
+
+// 各列表接口支持的查询参数
+var (
+	accountListOptions = listOptions{
+		Search: searchColumns("email", "name"),
+		Filters: map[string]listFilter{
+			"status":             {"status", filterText},
+			"interface_language": {"interface_language", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at":    "created_at",
+			"email":         "email",
+			"name":          "name",
+			"status":        "status",
+			"last_login_at": "last_login_at",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	adminRoleListOptions = listOptions{
+		Search: searchColumns("email"),
+		Filters: map[string]listFilter{
+			"role":       {"role", filterText},
+			"account_id": {"account_id", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+			"email":      "email",
+			"role":       "role",
+		},
+		DefaultSort:   "created_at",
+		DefaultOrder:  "asc",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	auditLogListOptions = listOptions{
+		Search: searchColumns("actor_email", "target_id"),
+		Filters: map[string]listFilter{
+			"action":      {"action", filterText},
+			"target_type": {"target_type", filterText},
+			"target_id":   {"target_id", filterText},
+			"tenant_id":   {"tenant_id", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at": "created_at",
+			"action":     "action",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	tenantListOptions = listOptions{
+		Search: searchColumns("name"),
+		Filters: map[string]listFilter{
+			"status": {"status", filterText},
+			"plan":   {"plan", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+			"name":       "name",
+			"plan":       "plan",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	datasetListOptions = listOptions{
+		Search: searchColumns("name", "description"),
+		Filters: map[string]listFilter{
+			"provider":                 {"provider", filterText},
+			"indexing_technique":       {"indexing_technique", filterText},
+			"permission":               {"permission", filterText},
+			"data_source_type":         {"data_source_type", filterText},
+			"embedding_model_provider": {"embedding_model_provider", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+			"name":       "name",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	appListOptions = listOptions{
+		Search: searchColumns("name", "description"),
+		Filters: map[string]listFilter{
+			"tenant_id":   {"tenant_id", filterUUID},
+			"mode":        {"mode", filterText},
+			"status":      {"status", filterText},
+			"enable_site": {"enable_site", filterBool},
+			"enable_api":  {"enable_api", filterBool},
+			"created_by":  {"created_by", filterUUID},
+		},
+		SortColumns: map[string]string{
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+			"name":       "name",
+			"mode":       "mode",
+		},
+		DefaultSort:   "updated_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	apiTokenListOptions = listOptions{
+		Filters: map[string]listFilter{
+			"tenant_id": {"tenant_id", filterUUID},
+			"app_id":    {"app_id", filterUUID},
+			"type":      {"type", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at":   "created_at",
+			"last_used_at": "last_used_at",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	providerListOptions = listOptions{
+		Search: searchColumns("provider_name"),
+		Filters: map[string]listFilter{
+			"tenant_id":     {"tenant_id", filterUUID},
+			"provider_name": {"provider_name", filterText},
+			"provider_type": {"provider_type", filterText},
+			"is_valid":      {"is_valid", filterBool},
+		},
+		SortColumns: map[string]string{
+			"created_at":    "created_at",
+			"updated_at":    "updated_at",
+			"provider_name": "provider_name",
+			"last_used":     "last_used",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	providerModelListOptions = listOptions{
+		Search: searchColumns("provider_name", "model_name"),
+		Filters: map[string]listFilter{
+			"tenant_id":     {"tenant_id", filterUUID},
+			"provider_name": {"provider_name", filterText},
+			"model_type":    {"model_type", filterText},
+			"is_valid":      {"is_valid", filterBool},
+		},
+		SortColumns: map[string]string{
+			"created_at":    "created_at",
+			"updated_at":    "updated_at",
+			"provider_name": "provider_name",
+			"model_name":    "model_name",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	tenantDefaultModelListOptions = listOptions{
+		Filters: map[string]listFilter{
+			"tenant_id":     {"tenant_id", filterUUID},
+			"provider_name": {"provider_name", filterText},
+			"model_type":    {"model_type", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+			"model_type": "model_type",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	// status 需要区分已过期的邀请，在 GetInvitations 中单独处理
+	invitationListOptions = listOptions{
+		Search: searchColumns("email"),
+		Filters: map[string]listFilter{
+			"account_id": {"account_id", filterText},
+			"tenant_id":  {"tenant_id", filterText},
+			"invited_by": {"invited_by", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at": "created_at",
+			"expires_at": "expires_at",
+			"email":      "email",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	passwordExpiryListOptions = listOptions{
+		Search: searchColumns("a.email", "a.name"),
+		Filters: map[string]listFilter{
+			"status": {"a.status", filterText},
+		},
+		SortColumns: map[string]string{
+			"password_changed_at": "h.changed_at",
+			"email":               "a.email",
+			"name":                "a.name",
+		},
+		DefaultSort:   "password_changed_at",
+		DefaultOrder:  "asc",
+		CreatedColumn: "a.created_at",
+		IDColumn:      "a.id",
+	}
+	loginThrottleListOptions = listOptions{
+		Search: searchColumns("value"),
+		Filters: map[string]listFilter{
+			"scope": {"scope", filterText},
+		},
+		SortColumns: map[string]string{
+			"updated_at":     "updated_at",
+			"last_failed_at": "last_failed_at",
+			"locked_until":   "locked_until",
+			"failures":       "failures",
+		},
+		DefaultSort:   "updated_at",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	documentListOptions = listOptions{
+		Search: searchColumns("name"),
+		Filters: map[string]listFilter{
+			"indexing_status":  {"indexing_status", filterText},
+			"enabled":          {"enabled", filterBool},
+			"archived":         {"archived", filterBool},
+			"doc_form":         {"doc_form", filterText},
+			"data_source_type": {"data_source_type", filterText},
+		},
+		SortColumns: map[string]string{
+			"position":   "position",
+			"created_at": "created_at",
+			"updated_at": "updated_at",
+			"name":       "name",
+			"word_count": "word_count",
+		},
+		DefaultSort:   "position",
+		DefaultOrder:  "asc",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	segmentListOptions = listOptions{
+		Search: searchColumns("content", "answer"),
+		Filters: map[string]listFilter{
+			"status":  {"status", filterText},
+			"enabled": {"enabled", filterBool},
+		},
+		SortColumns: map[string]string{
+			"position":   "position",
+			"created_at": "created_at",
+			"word_count": "word_count",
+			"hit_count":  "hit_count",
+		},
+		DefaultSort:   "position",
+		DefaultOrder:  "asc",
+		CreatedColumn: "created_at",
+		IDColumn:      "id",
+	}
+	// 成员关系使用 membershipQuery 联表查询，字段需要带表别名
+	tenantAccountListOptions = listOptions{
+		Search: searchColumns("a.email", "a.name", "t.name"),
+		Filters: map[string]listFilter{
+			"role":           {"j.role", filterText},
+			"current":        {"j.current", filterBool},
+			"account_status": {"a.status", filterText},
+			"tenant_plan":    {"t.plan", filterText},
+		},
+		SortColumns: map[string]string{
+			"created_at":    "j.created_at",
+			"updated_at":    "j.updated_at",
+			"role":          "j.role",
+			"account_email": "a.email",
+			"account_name":  "a.name",
+			"tenant_name":   "t.name",
+		},
+		DefaultSort:   "created_at",
+		CreatedColumn: "j.created_at",
+		IDColumn:      "j.id",
+	}
+)
+
+// listParams 解析后的分页和排序参数
+type listParams struct {
+	Page     int
+	PageSize int
+	orderBy  string
+}
+
+// searchColumns 在多个字段中模糊搜索，不区分大小写
+func searchColumns(columns ...string) func(query *gorm.DB, pattern string) *gorm.DB {
+	return func(query *gorm.DB, pattern string) *gorm.DB {
+		conditions := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			conditions[i] = column + " ILIKE ?"
+			args[i] = pattern
+		}
+		return query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+}
+
+// parseListQuery 解析列表接口的通用查询参数并应用到查询上
+// 支持 page、page_size、search、created_from、created_to、sort、order 以及 opts.Filters 中的筛选参数
+func parseListQuery(c *gin.Context, query *gorm.DB, opts listOptions) (*gorm.DB, listParams, error) {
+	var params listParams
+
+	params.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	params.PageSize, _ = strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if params.PageSize < 1 {
+		params.PageSize = defaultPageSize
+	}
+	if params.PageSize > maxPageSize {
+		params.PageSize = maxPageSize
+	}
+
+	// 模糊搜索
+	if search := strings.TrimSpace(c.Query("search")); search != "" && opts.Search != nil {
+		query = opts.Search(query, "%"+escapeLike(search)+"%")
+	}
+
+	// 字段筛选
+	for param, filter := range opts.Filters {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		values, err := parseFilterValues(param, filter.Type, strings.Split(value, ","))
+		if err != nil {
+			return nil, params, err
+		}
+		if len(values) == 1 {
+			query = query.Where(filter.Column+" = ?", values[0])
+		} else {
+			query = query.Where(filter.Column+" IN ?", values)
+		}
+	}
+
+	// 创建时间范围
+	if opts.CreatedColumn != "" {
+		if from := c.Query("created_from"); from != "" {
+			t, err := parseQueryTime(from)
+			if err != nil {
+				return nil, params, errors.New("created_from 时间格式错误")
+			}
+			query = query.Where(opts.CreatedColumn+" >= ?", t)
+		}
+		if to := c.Query("created_to"); to != "" {
+			t, err := parseQueryEndTime(to)
+			if err != nil {
+				return nil, params, errors.New("created_to 时间格式错误")
+			}
+			query = query.Where(opts.CreatedColumn+" <= ?", t)
+		}
+	}
+
+	// 排序
+	sort := c.DefaultQuery("sort", opts.DefaultSort)
+	column, ok := opts.SortColumns[sort]
+	if !ok {
+		return nil, params, fmt.Errorf("不支持按 %s 排序", sort)
+	}
+	defaultOrder := opts.DefaultOrder
+	if defaultOrder == "" {
+		defaultOrder = "desc"
+	}
+	order := strings.ToUpper(c.DefaultQuery("order", defaultOrder))
+	if order != "ASC" && order != "DESC" {
+		return nil, params, errors.New("order 只能是 asc 或 desc")
+	}
+	params.orderBy = column + " " + order
+	if opts.IDColumn != "" && opts.IDColumn != column {
+		params.orderBy += ", " + opts.IDColumn + " " + order
+	}
+
+	return query.Session(&gorm.Session{}), params, nil
+}
+
+// parseFilterValues 按筛选参数的类型解析参数值
+func parseFilterValues(param string, typ filterType, values []string) ([]interface{}, error) {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		switch typ {
+		case filterBool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s 只能是 true 或 false", param)
+			}
+			result[i] = b
+		case filterUUID:
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s 不是有效的ID: %s", param, value)
+			}
+			result[i] = id.String()
+		default:
+			result[i] = value
+		}
+	}
+	return result, nil
+}
+
+// paginate 为查询加上排序和分页
+func (p listParams) paginate(query *gorm.DB) *gorm.DB {
+	return query.Order(p.orderBy).Limit(p.PageSize).Offset((p.Page - 1) * p.PageSize)
+}
+
+// response 生成分页响应
+func (p listParams) response(data interface{}, total int64) models.PageResponse {
+	// 计算总页数
+	totalPages := int(math.Ceil(float64(total) / float64(p.PageSize)))
+
+	return models.PageResponse{
+		Data:       data,
+		Total:      total,
+		TotalPages: totalPages,
+		Page:       p.Page,
+		PageSize:   p.PageSize,
+	}
+}
+
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	defaultReportDays = 30  // 未指定时间范围时统计最近 30 天
+	maxReportDays     = 366 // 单次最多统计的天数
+)
+
+// reportSource 报表的数据来源，Alias 为主表别名，应用表固定关联为 a
+type reportSource struct {
+	Table         string // 主表及别名
+	Alias         string
+	TenantColumn  string // 工作空间ID字段
+	AccountColumn string // 操作用户ID字段，不是控制台用户时为空字符串
+	Metrics       string // 汇总字段
+	ExtraGroup    string // 除维度外额外的分组字段
+	DefaultOrder  string // 按工作空间、应用、用户分组时的排序
+}
+
+var (
+	// messages 没有 tenant_id，通过应用关联到工作空间
+	messageReportSource = reportSource{
+		Table:         "messages AS m",
+		Alias:         "m",
+		TenantColumn:  "a.tenant_id",
+		AccountColumn: "COALESCE(m.from_account_id::text, '')",
+		Metrics: "COALESCE(m.currency, '') AS currency, " +
+			"COUNT(*) AS message_count, " +
+			"COALESCE(SUM(m.message_tokens), 0) AS message_tokens, " +
+			"COALESCE(SUM(m.answer_tokens), 0) AS answer_tokens, " +
+			"COALESCE(SUM(m.message_tokens + m.answer_tokens), 0) AS total_tokens, " +
+			"COALESCE(SUM(m.total_price), 0) AS total_price, " +
+			"COALESCE(AVG(m.provider_response_latency), 0) AS avg_latency",
+		ExtraGroup:   "currency",
+		DefaultOrder: "total_tokens DESC",
+	}
+	workflowRunReportSource = reportSource{
+		Table:         "workflow_runs AS r",
+		Alias:         "r",
+		TenantColumn:  "r.tenant_id",
+		AccountColumn: "CASE WHEN r.created_by_role = 'account' THEN r.created_by::text ELSE '' END",
+		Metrics: "COUNT(*) AS run_count, " +
+			"COUNT(*) FILTER (WHERE r.status = 'succeeded') AS succeeded_count, " +
+			"COUNT(*) FILTER (WHERE r.status = 'failed') AS failed_count, " +
+			"COUNT(*) FILTER (WHERE r.status = 'stopped') AS stopped_count, " +
+			"COALESCE(SUM(r.total_tokens), 0) AS total_tokens, " +
+			"COALESCE(SUM(r.total_steps), 0) AS total_steps, " +
+			"COALESCE(SUM(r.elapsed_time), 0) AS total_elapsed_time, " +
+			"COALESCE(AVG(r.elapsed_time), 0) AS avg_elapsed_time",
+		DefaultOrder: "run_count DESC",
+	}
+)
+
+// 报表导出的列
+var (
+	messageReportColumns = []exportColumn[models.MessageReportRow]{
+		{"group_key", func(r models.MessageReportRow) interface{} { return r.GroupKey }},
+		{"group_name", func(r models.MessageReportRow) interface{} { return r.GroupName }},
+		{"currency", func(r models.MessageReportRow) interface{} { return r.Currency }},
+		{"message_count", func(r models.MessageReportRow) interface{} { return r.MessageCount }},
+		{"message_tokens", func(r models.MessageReportRow) interface{} { return r.MessageTokens }},
+		{"answer_tokens", func(r models.MessageReportRow) interface{} { return r.AnswerTokens }},
+		{"total_tokens", func(r models.MessageReportRow) interface{} { return r.TotalTokens }},
+		{"total_price", func(r models.MessageReportRow) interface{} { return r.TotalPrice }},
+		{"avg_latency", func(r models.MessageReportRow) interface{} { return r.AvgLatency }},
+	}
+	workflowRunReportColumns = []exportColumn[models.WorkflowRunReportRow]{
+		{"group_key", func(r models.WorkflowRunReportRow) interface{} { return r.GroupKey }},
+		{"group_name", func(r models.WorkflowRunReportRow) interface{} { return r.GroupName }},
+		{"run_count", func(r models.WorkflowRunReportRow) interface{} { return r.RunCount }},
+		{"succeeded_count", func(r models.WorkflowRunReportRow) interface{} { return r.SucceededCount }},
+		{"failed_count", func(r models.WorkflowRunReportRow) interface{} { return r.FailedCount }},
+		{"stopped_count", func(r models.WorkflowRunReportRow) interface{} { return r.StoppedCount }},
+		{"total_tokens", func(r models.WorkflowRunReportRow) interface{} { return r.TotalTokens }},
+		{"total_steps", func(r models.WorkflowRunReportRow) interface{} { return r.TotalSteps }},
+		{"total_elapsed_time", func(r models.WorkflowRunReportRow) interface{} { return r.TotalElapsedTime }},
+		{"avg_elapsed_time", func(r models.WorkflowRunReportRow) interface{} { return r.AvgElapsedTime }},
+	}
+)
+
+// GetMessageReport 对话消息的 token 用量和费用，按工作空间、应用、用户、天或月汇总
+func GetMessageReport(c *gin.Context) {
+	runReport(c, "message_report", messageReportSource, messageReportColumns)
+}
+
+// GetWorkflowRunReport 工作流运行次数、token 用量和耗时，按工作空间、应用、用户、天或月汇总
+func GetWorkflowRunReport(c *gin.Context) {
+	runReport(c, "workflow_run_report", workflowRunReportSource, workflowRunReportColumns)
+}
+
+// runReport 解析报表参数并执行汇总查询
+// 参数：group_by（tenant、app、account、day、month，默认 tenant）、start、end、timezone（按天、月分组使用的时区，默认 UTC）、
+// tenant_id、app_id、account_id 筛选，format 为 csv 或 ndjson 时导出文件
+func runReport[T any](c *gin.Context, name string, source reportSource, columns []exportColumn[T]) {
+	groupBy := c.DefaultQuery("group_by", "tenant")
+	timezone := c.DefaultQuery("timezone", "UTC")
+	loc, err := time.LoadLocation(timezone)
+	if timezone == "Local" || err != nil {
+		c.JSON(400, gin.H{"error": "无效的时区"})
+		return
+	}
+
+	if tenantID := c.Query("tenant_id"); tenantID != "" && !requireTenantInScope(c, tenantID) {
+		return
+	}
+
+	start, end, err := parseReportRange(c, loc)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	query, err := source.query(c, groupBy, timezone)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	query, err = scopeByTenant(c, query, source.TenantColumn)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	// Dify 的时间字段是不带时区的 UTC 时间
+	query = query.Where(source.Alias+".created_at >= ? AND "+source.Alias+".created_at < ?", start.UTC(), end.UTC())
+
+	if c.Query("format") != "" {
+		streamExport(c, fmt.Sprintf("%s_%s", name, groupBy), query, columns)
+		return
+	}
+
+	var rows []T
+	if err := query.Scan(&rows).Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if rows == nil {
+		rows = []T{}
+	}
+
+	c.JSON(200, models.ReportResponse{
+		GroupBy:  groupBy,
+		Start:    start.Format(time.RFC3339),
+		End:      end.Format(time.RFC3339),
+		Timezone: timezone,
+		Data:     rows,
+	})
+}
+
+// query 构建汇总查询，包含分组和筛选
+func (s reportSource) query(c *gin.Context, groupBy, timezone string) (*gorm.DB, error) {
+	query := database.DB.Table(s.Table).Joins("LEFT JOIN apps a ON a.id = " + s.Alias + ".app_id")
+
+	var key, label string
+	var args []interface{}
+	order := s.DefaultOrder
+	switch groupBy {
+	case "tenant":
+		query = query.Joins("LEFT JOIN tenants t ON t.id = " + s.TenantColumn)
+		key, label = s.TenantColumn+"::text", "COALESCE(t.name, '')"
+	case "app":
+		key, label = s.Alias+".app_id::text", "COALESCE(a.name, '')"
+	case "account":
+		query = query.Joins("LEFT JOIN accounts acc ON acc.id::text = " + s.AccountColumn)
+		key, label = s.AccountColumn, "COALESCE(acc.email, '')"
+	case "day", "month":
+		format := "YYYY-MM-DD"
+		if groupBy == "month" {
+			format = "YYYY-MM"
+		}
+		key = "to_char((" + s.Alias + ".created_at AT TIME ZONE 'UTC') AT TIME ZONE ?, '" + format + "')"
+		label = key
+		args = []interface{}{timezone, timezone}
+		order = "group_key"
+	default:
+		return nil, errors.New("group_by 只支持 tenant、app、account、day、month")
+	}
+
+	group := "group_key, group_name"
+	if s.ExtraGroup != "" {
+		group += ", " + s.ExtraGroup
+	}
+	query = query.Select(key+" AS group_key, "+label+" AS group_name, "+s.Metrics, args...).
+		Group(group).
+		Order(order)
+
+	if tenantID := c.Query("tenant_id"); tenantID != "" {
+		query = query.Where(s.TenantColumn+" = ?", tenantID)
+	}
+	if appID := c.Query("app_id"); appID != "" {
+		query = query.Where(s.Alias+".app_id = ?", appID)
+	}
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where(s.AccountColumn+" = ?", accountID)
+	}
+
+	return query, nil
+}
+
+// parseReportRange 解析报表的时间范围，只传日期时按指定时区解析，结束日期包含当天
+func parseReportRange(c *gin.Context, loc *time.Location) (time.Time, time.Time, error) {
+	parse := func(value string) (time.Time, bool, error) {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, false, nil
+		}
+		t, err := time.ParseInLocation("2006-01-02", value, loc)
+		return t, true, err
+	}
+
+	now := time.Now().In(loc)
+	end := now
+	if value := c.Query("end"); value != "" {
+		t, dateOnly, err := parse(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("end 时间格式错误")
+		}
+		end = t
+		if dateOnly {
+			end = t.AddDate(0, 0, 1)
+		}
+	}
+
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -defaultReportDays+1)
+	if value := c.Query("start"); value != "" {
+		t, _, err := parse(value)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("start 时间格式错误")
+		}
+		start = t
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.New("start 必须早于 end")
+	}
+	if end.Sub(start) > maxReportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("时间范围不能超过%d天", maxReportDays)
+	}
+	return start, end, nil
+}
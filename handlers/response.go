@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+)
+
+// accountResponses 转换用户列表，并统计每个用户加入的工作空间数量
+func accountResponses(accounts []models.Account) ([]models.AccountResponse, error) {
+	ids := make([]string, len(accounts))
+	for i, account := range accounts {
+		ids[i] = account.ID
+	}
+	counts, err := countGroupBy(&models.TenantAccountJoin{}, "account_id", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.AccountResponse, len(accounts))
+	for i, account := range accounts {
+		responses[i] = models.NewAccountResponse(account)
+		responses[i].TenantCount = counts[account.ID]
+	}
+	return responses, nil
+}
+
+// tenantResponses 转换工作空间列表，并统计每个工作空间的成员数量
+func tenantResponses(tenants []models.Tenant) ([]models.TenantResponse, error) {
+	ids := make([]string, len(tenants))
+	for i, tenant := range tenants {
+		ids[i] = tenant.ID
+	}
+	counts, err := countGroupBy(&models.TenantAccountJoin{}, "tenant_id", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.TenantResponse, len(tenants))
+	for i, tenant := range tenants {
+		responses[i] = models.NewTenantResponse(tenant)
+		responses[i].MemberCount = counts[tenant.ID]
+	}
+	return responses, nil
+}
+
+func tenantAccountJoinResponses(joins []models.TenantAccountJoin) []models.TenantAccountJoinResponse {
+	responses := make([]models.TenantAccountJoinResponse, len(joins))
+	for i, join := range joins {
+		responses[i] = models.NewTenantAccountJoinResponse(join)
+	}
+	return responses
+}
+
+func datasetResponses(datasets []models.Dataset) []models.DatasetResponse {
+	responses := make([]models.DatasetResponse, len(datasets))
+	for i, dataset := range datasets {
+		responses[i] = models.NewDatasetResponse(dataset)
+	}
+	return responses
+}
+
+// countGroupBy 按指定字段分组统计 ids 中每个值的记录数
+func countGroupBy(model interface{}, column string, ids []string) (map[string]int64, error) {
+	counts := map[string]int64{}
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		GroupKey string
+		Count    int64
+	}
+	err := database.DB.Model(model).
+		Select(column+" AS group_key, COUNT(*) AS count").
+		Where(column+" IN ?", ids).
+		Group(column).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		counts[row.GroupKey] = row.Count
+	}
+	return counts, nil
+}
+
+// documentResponses 转换文档列表，并统计每个文档的分段数
+func documentResponses(documents []models.Document) ([]models.DocumentResponse, error) {
+	ids := make([]string, len(documents))
+	for i, document := range documents {
+		ids[i] = document.ID
+	}
+	counts, err := countGroupBy(&models.DocumentSegment{}, "document_id", ids)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.DocumentResponse, len(documents))
+	for i, document := range documents {
+		responses[i] = models.NewDocumentResponse(document)
+		responses[i].SegmentCount = counts[document.ID]
+	}
+	return responses, nil
+}
+
+func segmentResponses(segments []models.DocumentSegment) []models.DocumentSegmentResponse {
+	responses := make([]models.DocumentSegmentResponse, len(segments))
+	for i, segment := range segments {
+		responses[i] = models.NewDocumentSegmentResponse(segment)
+	}
+	return responses
+}
+
+// appResponses 转换应用列表，并查询所属工作空间名称和创建人邮箱
+func appResponses(apps []models.App) ([]models.AppResponse, error) {
+	var tenantIDs, accountIDs []string
+	for _, app := range apps {
+		tenantIDs = append(tenantIDs, app.TenantID)
+		if app.CreatedBy != nil {
+			accountIDs = append(accountIDs, *app.CreatedBy)
+		}
+	}
+
+	tenantNames, err := tenantNameMap(tenantIDs)
+	if err != nil {
+		return nil, err
+	}
+	emails := map[string]string{}
+	if len(accountIDs) > 0 {
+		var accounts []models.Account
+		if err := database.DB.Select("id, email").Where("id IN ?", accountIDs).Find(&accounts).Error; err != nil {
+			return nil, err
+		}
+		for _, account := range accounts {
+			emails[account.ID] = account.Email
+		}
+	}
+
+	responses := make([]models.AppResponse, len(apps))
+	for i, app := range apps {
+		responses[i] = models.NewAppResponse(app)
+		responses[i].TenantName = tenantNames[app.TenantID]
+		if app.CreatedBy != nil {
+			responses[i].CreatedByEmail = emails[*app.CreatedBy]
+		}
+	}
+	return responses, nil
+}
+
+// apiTokenResponses 转换 API 密钥列表，密钥脱敏，并查询所属应用和工作空间名称
+func apiTokenResponses(tokens []models.APIToken) ([]models.APITokenResponse, error) {
+	var appIDs, tenantIDs []string
+	for _, token := range tokens {
+		if token.AppID != nil {
+			appIDs = append(appIDs, *token.AppID)
+		}
+		if token.TenantID != nil {
+			tenantIDs = append(tenantIDs, *token.TenantID)
+		}
+	}
+
+	appNames := map[string]string{}
+	if len(appIDs) > 0 {
+		var apps []models.App
+		if err := database.DB.Select("id, name").Where("id IN ?", appIDs).Find(&apps).Error; err != nil {
+			return nil, err
+		}
+		for _, app := range apps {
+			appNames[app.ID] = app.Name
+		}
+	}
+	tenantNames, err := tenantNameMap(tenantIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]models.APITokenResponse, len(tokens))
+	for i, token := range tokens {
+		responses[i] = models.NewAPITokenResponse(token)
+		if token.AppID != nil {
+			responses[i].AppName = appNames[*token.AppID]
+		}
+		if token.TenantID != nil {
+			responses[i].TenantName = tenantNames[*token.TenantID]
+		}
+	}
+	return responses, nil
+}
+
+// tenantNameMap 查询工作空间 ID 对应的名称
+func tenantNameMap(tenantIDs []string) (map[string]string, error) {
+	names := map[string]string{}
+	if len(tenantIDs) == 0 {
+		return names, nil
+	}
+	var tenants []models.Tenant
+	if err := database.DB.Select("id, name").Where("id IN ?", tenantIDs).Find(&tenants).Error; err != nil {
+		return nil, err
+	}
+	for _, tenant := range tenants {
+		names[tenant.ID] = tenant.Name
+	}
+	return names, nil
+}
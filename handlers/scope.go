@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"difyserver/config"
+	"difyserver/database"
+	"difyserver/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// tenantScope 获取当前管理员可管理的工作空间ID
+// restricted 为 false 表示不受限制；超级管理员不受限制，工作空间管理员始终受限，
+// 其他角色绑定了工作空间时受限
+func tenantScope(c *gin.Context) (tenantIDs []string, restricted bool, err error) {
+	if cached, ok := c.Get("tenantScope"); ok {
+		tenantIDs = cached.([]string)
+		return tenantIDs, c.GetBool("tenantScopeRestricted"), nil
+	}
+
+	role := c.GetString("userRole")
+	if role != models.AdminRoleSuperAdmin {
+		if err = database.DB.Model(&models.AdminTenant{}).
+			Where("account_id = ?", c.GetString("userID")).
+			Pluck("tenant_id", &tenantIDs).Error; err != nil {
+			return nil, false, err
+		}
+		restricted = role == models.AdminRoleTenantAdmin || len(tenantIDs) > 0
+	}
+
+	if tenantIDs == nil {
+		tenantIDs = []string{}
+	}
+	c.Set("tenantScope", tenantIDs)
+	c.Set("tenantScopeRestricted", restricted)
+	return tenantIDs, restricted, nil
+}
+
+// tenantInScope 判断工作空间是否在当前管理员的管理范围内
+func tenantInScope(c *gin.Context, tenantID string) (bool, error) {
+	tenantIDs, restricted, err := tenantScope(c)
+	if err != nil {
+		return false, err
+	}
+	if !restricted {
+		return true, nil
+	}
+	for _, id := range tenantIDs {
+		if id == tenantID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// accountInScope 判断用户是否完全在当前管理员的管理范围内
+// 受限管理员只能管理加入了其管理的工作空间、且没有加入其他工作空间的用户
+func accountInScope(c *gin.Context, accountID string) (bool, error) {
+	tenantIDs, restricted, err := tenantScope(c)
+	if err != nil {
+		return false, err
+	}
+	if !restricted {
+		return true, nil
+	}
+
+	var inside, outside int64
+	if err := database.DB.Model(&models.TenantAccountJoin{}).
+		Where("account_id = ? AND tenant_id IN ?", accountID, tenantIDs).
+		Count(&inside).Error; err != nil {
+		return false, err
+	}
+	if err := database.DB.Model(&models.TenantAccountJoin{}).
+		Where("account_id = ? AND tenant_id NOT IN ?", accountID, tenantIDs).
+		Count(&outside).Error; err != nil {
+		return false, err
+	}
+	return inside > 0 && outside == 0, nil
+}
+
+// scopeByTenant 按当前管理员的管理范围过滤带 tenant_id 字段的查询
+func scopeByTenant(c *gin.Context, query *gorm.DB, column string) (*gorm.DB, error) {
+	tenantIDs, restricted, err := tenantScope(c)
+	if err != nil {
+		return nil, err
+	}
+	if restricted {
+		query = query.Where(column+" IN ?", tenantIDs)
+	}
+	return query, nil
+}
+
+// scopeByAccount 按当前管理员的管理范围过滤用户查询，只保留其管理的工作空间中的用户
+func scopeByAccount(c *gin.Context, query *gorm.DB, column string) (*gorm.DB, error) {
+	tenantIDs, restricted, err := tenantScope(c)
+	if err != nil {
+		return nil, err
+	}
+	if restricted {
+		members := database.DB.Model(&models.TenantAccountJoin{}).Select("account_id").Where("tenant_id IN ?", tenantIDs)
+		query = query.Where(column+" IN (?)", members)
+	}
+	return query, nil
+}
+
+// requireTenantInScope 校验工作空间是否在管理范围内，不在范围内时直接返回错误响应
+func requireTenantInScope(c *gin.Context, tenantID string) bool {
+	ok, err := tenantInScope(c, tenantID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+	if !ok {
+		c.JSON(403, gin.H{"error": "没有权限管理该工作空间"})
+		return false
+	}
+	return true
+}
+
+// requireAccountInScope 校验用户是否在管理范围内，不在范围内时直接返回错误响应
+func requireAccountInScope(c *gin.Context, accountID string) bool {
+	ok, err := accountInScope(c, accountID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+	if !ok {
+		c.JSON(403, gin.H{"error": "没有权限管理该用户"})
+		return false
+	}
+
+	// 只有超级管理员可以管理管理员的账号，避免重置其他管理员的密码后冒用其身份
+	if c.GetString("userRole") != models.AdminRoleSuperAdmin {
+		isAdmin, err := isAdminAccount(accountID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return false
+		}
+		if isAdmin {
+			c.JSON(403, gin.H{"error": "没有权限管理管理员账号"})
+			return false
+		}
+	}
+	return true
+}
+
+// isAdminAccount 判断用户是否为管理后台的管理员，包括 admin_roles 中的用户和 config.yaml 中 admins 列出的邮箱
+func isAdminAccount(accountID string) (bool, error) {
+	var count int64
+	if err := database.DB.Model(&models.AdminRole{}).Where("account_id = ?", accountID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	var account models.Account
+	if err := database.DB.Select("email").Where("id = ?", accountID).First(&account).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, email := range config.GlobalConfig.Admins {
+		if strings.EqualFold(email, account.Email) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/models"
+	"difyserver/storage"
+	"difyserver/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"log"
+	"strings"
+)
+
+func UpdateTenant(c *gin.Context) {
+	var req struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.ID == "" || req.Name == "" {
+		c.JSON(400, gin.H{"error": "工作空间ID和名称不能为空"})
+		return
+	}
+	if len([]rune(req.Name)) > 255 {
+		c.JSON(400, gin.H{"error": "工作空间名称不能超过255个字符"})
+		return
+	}
+
+	if !requireTenantInScope(c, req.ID) {
+		return
+	}
+
+	if !updateTenantField(c, req.ID, "name", req.Name, AuditUpdateTenant) {
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "修改工作空间成功"})
+}
+
+func SetTenantPlan(c *gin.Context) {
+	var req struct {
+		ID   string `json:"id"`
+		Plan string `json:"plan"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" || req.Plan == "" {
+		c.JSON(400, gin.H{"error": "工作空间ID和计划不能为空"})
+		return
+	}
+	if !models.ValidTenantPlans[req.Plan] {
+		c.JSON(400, gin.H{"error": "无效的工作空间计划"})
+		return
+	}
+
+	if !requireTenantInScope(c, req.ID) {
+		return
+	}
+
+	if !updateTenantField(c, req.ID, "plan", req.Plan, AuditSetTenantPlan) {
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "修改工作空间计划成功"})
+}
+
+// SetTenantStatus 归档（archive）或恢复（normal）工作空间
+func SetTenantStatus(c *gin.Context) {
+	var req struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" || req.Status == "" {
+		c.JSON(400, gin.H{"error": "工作空间ID和状态不能为空"})
+		return
+	}
+	if !models.ValidTenantStatuses[req.Status] {
+		c.JSON(400, gin.H{"error": "无效的工作空间状态"})
+		return
+	}
+
+	if !requireTenantInScope(c, req.ID) {
+		return
+	}
+
+	if !updateTenantField(c, req.ID, "status", req.Status, AuditSetTenantStatus) {
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "修改工作空间状态成功"})
+}
+
+// updateTenantField 修改工作空间的单个字段并写入审计日志，失败时直接返回错误响应
+func updateTenantField(c *gin.Context, tenantID, column string, value interface{}, action string) bool {
+	// 开启事务
+	tx := database.DB.Begin()
+
+	var tenant models.Tenant
+	if err := tx.Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return false
+	}
+
+	before := map[string]interface{}{"name": tenant.Name, "plan": tenant.Plan, "status": tenant.Status}
+	if err := tx.Model(&tenant).Update(column, value).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+
+	after := gin.H{column: value}
+	if err := writeAuditLog(c, tx, action, AuditTargetTenant, tenant.ID, tenant.ID, gin.H{column: before[column]}, after); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return false
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return false
+	}
+
+	return true
+}
+
+// PreviewDelTenant 预览删除工作空间的影响：将被移除的成员数，会失去归属的知识库和应用，以及一并删除的配置记录
+func PreviewDelTenant(c *gin.Context) {
+	tenantID := c.Query("id")
+	if tenantID == "" {
+		c.JSON(400, gin.H{"error": "id 参数必填"})
+		return
+	}
+	if !requireTenantInScope(c, tenantID) {
+		return
+	}
+
+	preview, err := tenantDeletePreview(database.DB, tenantID)
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, preview)
+}
+
+// DelTenant 删除工作空间，在同一事务中删除其成员关系、管理员绑定、模型供应商配置、API 密钥和邀请，提交后删除私钥
+// 工作空间下还有知识库或应用时，需要传入 force=true 才会删除，这些数据会失去归属
+func DelTenant(c *gin.Context) {
+	var req struct {
+		ID    string `json:"id"`
+		Force bool   `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ID == "" {
+		c.JSON(400, gin.H{"error": "工作空间ID不能为空"})
+		return
+	}
+
+	// 受限管理员不能删除工作空间
+	if _, restricted, err := tenantScope(c); err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	} else if restricted {
+		c.JSON(403, gin.H{"error": "没有权限删除工作空间"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	preview, err := tenantDeletePreview(tx, req.ID)
+	if err == gorm.ErrRecordNotFound {
+		tx.Rollback()
+		c.JSON(404, gin.H{"error": "未找到指定工作空间"})
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (len(preview.Datasets) > 0 || len(preview.Apps) > 0) && !req.Force {
+		tx.Rollback()
+		c.JSON(409, gin.H{
+			"error":   "工作空间下还有知识库或应用，确认删除请传入 force=true",
+			"preview": preview,
+		})
+		return
+	}
+
+	var joins []models.TenantAccountJoin
+	if err := tx.Where("tenant_id = ?", req.ID).Find(&joins).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Where("tenant_id = ?", req.ID).Delete(&models.TenantAccountJoin{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "删除成员关系失败"})
+		return
+	}
+	if err := tx.Where("tenant_id = ?", req.ID).Delete(&models.AdminTenant{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "删除管理员绑定失败"})
+		return
+	}
+	m := newTableMover(tx)
+	for _, table := range tenantOwnedTables {
+		if !m.hasTable(table) {
+			continue
+		}
+		if err := m.exec(table, "delete", "", "DELETE FROM "+table+" WHERE tenant_id = ?", req.ID); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	preview.Deleted = m.changes
+
+	if err := tx.Where("id = ?", req.ID).Delete(&models.Tenant{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "删除工作空间失败"})
+		return
+	}
+
+	// 被删除的工作空间是成员的当前工作空间时，为其重新选择当前工作空间
+	for _, join := range joins {
+		if !join.Current {
+			continue
+		}
+		if err := ensureCurrentTenant(tx, join.AccountID); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	before := gin.H{"tenant": preview.Tenant, "tenant_account_joins": joins, "datasets": preview.Datasets, "apps": preview.Apps, "deleted": preview.Deleted}
+	if err := writeAuditLog(c, tx, AuditDelTenant, AuditTargetTenant, req.ID, req.ID, before, nil); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 事务提交后再删除私钥，避免删除失败回滚后工作空间没有私钥
+	deletePrivateKey(req.ID)
+
+	c.JSON(200, gin.H{
+		"message": "删除工作空间成功",
+		"preview": preview,
+	})
+}
+
+// tenantOwnedTables 只属于工作空间、随工作空间一并删除的表，工作空间私钥在事务提交后删除
+var tenantOwnedTables = []string{
+	"providers",
+	"provider_models",
+	"tenant_default_models",
+	"tenant_preferred_model_providers",
+	"api_tokens",
+	"difyserver_invitations",
+}
+
+// tenantDeletePreview 统计删除工作空间的影响，工作空间不存在时返回 gorm.ErrRecordNotFound
+func tenantDeletePreview(db *gorm.DB, tenantID string) (*models.TenantDeletePreview, error) {
+	var tenant models.Tenant
+	if err := db.Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		return nil, err
+	}
+
+	preview := &models.TenantDeletePreview{
+		Tenant:   models.NewTenantResponse(tenant),
+		Datasets: []models.OrphanDataset{},
+		Apps:     []models.OrphanApp{},
+	}
+	if err := db.Model(&models.TenantAccountJoin{}).Where("tenant_id = ?", tenantID).Count(&preview.MemberCount).Error; err != nil {
+		return nil, err
+	}
+	preview.Tenant.MemberCount = preview.MemberCount
+
+	if err := db.Model(&models.Dataset{}).Select("id, name").Where("tenant_id = ?", tenantID).Order("created_at").Scan(&preview.Datasets).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Table("apps").Select("id, name, mode").Where("tenant_id = ?", tenantID).Order("created_at").Scan(&preview.Apps).Error; err != nil {
+		return nil, err
+	}
+
+	preview.Deleted = []models.TableChange{}
+	for _, table := range tenantOwnedTables {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+		var count int64
+		if err := db.Table(table).Where("tenant_id = ?", tenantID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		preview.Deleted = append(preview.Deleted, models.TableChange{Table: table, Action: "delete", Rows: count})
+	}
+
+	return preview, nil
+}
+
+// deletePrivateKey 删除工作空间的私钥，用于创建工作空间失败或删除工作空间后，删除失败只记录日志
+func deletePrivateKey(tenantID string) {
+	if err := storage.Default.Delete(utils.PrivateKeyPath(tenantID)); err != nil {
+		log.Println("删除工作空间私钥失败:", tenantID, err)
+	}
+}
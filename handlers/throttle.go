@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"difyserver/config"
+	"difyserver/database"
+	"difyserver/models"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 登录失败的原因，记录在审计日志中
+const (
+	loginFailUnknownEmail    = "unknown_email"
+	loginFailAccountDisabled = "account_disabled"
+	loginFailNoPassword      = "no_password"
+	loginFailWrongPassword   = "wrong_password"
+	loginFailNotAdmin        = "not_admin"
+)
+
+// loginAttempt 一次需要校验密码的请求，在事务中锁定来源 IP 和邮箱的失败记录
+// 同一 IP 或邮箱的请求依次处理，并发请求不能绕过失败次数限制
+type loginAttempt struct {
+	tx      *gorm.DB
+	email   string
+	records []models.LoginThrottle
+	now     time.Time
+}
+
+// beginLoginAttempt 开启事务并锁定失败记录，调用方需调用 rejectIfThrottled 后再调用 fail 或 succeed 结束事务
+func beginLoginAttempt(c *gin.Context, email string) (*loginAttempt, error) {
+	a := &loginAttempt{
+		tx:    database.DB.Begin(),
+		email: strings.ToLower(strings.TrimSpace(email)),
+		now:   time.Now(),
+	}
+	if config.GlobalConfig.LoginThrottle.Disabled {
+		return a, nil
+	}
+
+	// 按固定顺序逐个加锁，避免并发请求互相等待形成死锁
+	keys := []struct{ scope, value string }{
+		{models.LoginThrottleScopeIP, c.ClientIP()},
+		{models.LoginThrottleScopeEmail, a.email},
+	}
+	for _, key := range keys {
+		record := models.LoginThrottle{
+			ID:        uuid.New().String(),
+			Scope:     key.scope,
+			Value:     key.value,
+			ExpiresAt: a.now,
+			CreatedAt: a.now,
+			UpdatedAt: a.now,
+		}
+		if err := a.tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "scope"}, {Name: "value"}},
+			DoNothing: true,
+		}).Create(&record).Error; err != nil {
+			a.tx.Rollback()
+			return nil, err
+		}
+		if err := a.tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND value = ?", key.scope, key.value).First(&record).Error; err != nil {
+			a.tx.Rollback()
+			return nil, err
+		}
+		a.records = append(a.records, record)
+	}
+	return a, nil
+}
+
+// rejectIfThrottled 来源 IP 或邮箱仍在等待或锁定时结束事务并返回 429
+func (a *loginAttempt) rejectIfThrottled(c *gin.Context) bool {
+	var wait time.Duration
+	for _, record := range a.records {
+		if record.LockedUntil != nil && record.LockedUntil.Sub(a.now) > wait {
+			wait = record.LockedUntil.Sub(a.now)
+		}
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	a.tx.Rollback()
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(429, gin.H{
+		"error":       fmt.Sprintf("登录失败次数过多，请%d秒后重试", seconds),
+		"code":        "login_throttled",
+		"retry_after": seconds,
+	})
+	return true
+}
+
+// fail 记录一次失败并写入审计日志，达到上限时锁定，否则按失败次数指数增加等待时间
+// account 为空表示邮箱不存在
+func (a *loginAttempt) fail(c *gin.Context, account *models.Account, reason string) error {
+	cfg := config.GlobalConfig.LoginThrottle
+	window := time.Duration(cfg.WindowMinutes) * time.Minute
+	after := gin.H{"email": a.email, "reason": reason}
+
+	for i := range a.records {
+		record := &a.records[i]
+
+		// 超过统计窗口没有再失败时重新计数
+		if record.LastFailedAt != nil && a.now.Sub(*record.LastFailedAt) > window {
+			record.Failures = 0
+		}
+		record.Failures++
+
+		limit := cfg.MaxFailures
+		if record.Scope == models.LoginThrottleScopeIP {
+			limit = cfg.IPMaxFailures
+		}
+		wait := loginBackoff(record.Failures)
+		if record.Failures >= limit {
+			wait = time.Duration(cfg.LockoutMinutes) * time.Minute
+			after[record.Scope+"_locked"] = true
+		}
+		lockedUntil := a.now.Add(wait)
+
+		record.LastFailedAt = &a.now
+		record.LockedUntil = &lockedUntil
+		record.ExpiresAt = a.now.Add(window)
+		if lockedUntil.After(record.ExpiresAt) {
+			record.ExpiresAt = lockedUntil
+		}
+		record.UpdatedAt = a.now
+		if err := a.tx.Save(record).Error; err != nil {
+			a.tx.Rollback()
+			return err
+		}
+		after[record.Scope+"_failures"] = record.Failures
+		after[record.Scope+"_locked_until"] = lockedUntil
+	}
+
+	// 顺便清理已过期的记录
+	if !cfg.Disabled {
+		if err := a.tx.Where("expires_at < ?", a.now).Delete(&models.LoginThrottle{}).Error; err != nil {
+			a.tx.Rollback()
+			return err
+		}
+	}
+
+	targetID := ""
+	if account != nil {
+		targetID = account.ID
+	}
+	if err := writeAuditLog(c, a.tx, AuditLoginFailed, AuditTargetAccount, targetID, "", nil, after); err != nil {
+		a.tx.Rollback()
+		return err
+	}
+
+	return a.tx.Commit().Error
+}
+
+// succeed 密码正确，清除该邮箱的失败记录
+// 来源 IP 的记录保留到过期，避免用已知的账号重置 IP 的失败次数后继续尝试其他账号
+func (a *loginAttempt) succeed() error {
+	if !config.GlobalConfig.LoginThrottle.Disabled {
+		if err := a.tx.Where("scope = ? AND value = ?", models.LoginThrottleScopeEmail, a.email).
+			Delete(&models.LoginThrottle{}).Error; err != nil {
+			a.tx.Rollback()
+			return err
+		}
+	}
+	return a.tx.Commit().Error
+}
+
+// failLogin 记录登录失败并返回 401
+func failLogin(c *gin.Context, attempt *loginAttempt, account *models.Account, reason, message string) {
+	if err := attempt.fail(c, account, reason); err != nil {
+		c.JSON(500, gin.H{"error": "写入登录失败记录失败"})
+		return
+	}
+	c.JSON(401, gin.H{"error": message})
+}
+
+// loginBackoff 第 n 次失败后需要等待的时间，从 base_delay 开始每次翻倍，不超过 max_delay
+func loginBackoff(failures int) time.Duration {
+	cfg := config.GlobalConfig.LoginThrottle
+	delay := cfg.BaseDelay
+	for i := 1; i < failures && delay < cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+	return time.Duration(delay) * time.Second
+}
+
+// GetLoginThrottles 查看登录失败记录，locked=true 时只返回当前不能登录的 IP 和邮箱
+func GetLoginThrottles(c *gin.Context) {
+	var records []models.LoginThrottle
+	var total int64
+	now := time.Now()
+
+	// 登录成功的请求也会留下失败次数为 0 的记录，不返回
+	query := database.DB.Model(&models.LoginThrottle{}).Where("failures > 0 AND expires_at >= ?", now)
+	if c.Query("locked") == "true" {
+		query = query.Where("locked_until > ?", now)
+	}
+
+	query, params, err := parseListQuery(c, query, loginThrottleListOptions)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 先获取总记录数
+	query.Count(&total)
+
+	// 获取分页数据
+	result := params.paginate(query).Find(&records)
+	if result.Error != nil {
+		c.JSON(500, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	// 与登录时的判断一致，只按 LockedUntil 判断当前是否被拒绝，锁定到期后不再显示为锁定
+	responses := make([]models.LoginThrottleResponse, len(records))
+	for i, record := range records {
+		responses[i] = models.LoginThrottleResponse{
+			ID:           record.ID,
+			Scope:        record.Scope,
+			Value:        record.Value,
+			Failures:     record.Failures,
+			LastFailedAt: record.LastFailedAt,
+			LockedUntil:  record.LockedUntil,
+			UpdatedAt:    record.UpdatedAt,
+		}
+		if record.LockedUntil != nil && record.LockedUntil.After(now) {
+			responses[i].Locked = true
+			responses[i].RetryAfter = int(math.Ceil(record.LockedUntil.Sub(now).Seconds()))
+		}
+	}
+
+	c.JSON(200, params.response(responses, total))
+}
+
+// ClearLoginThrottle 清除指定 IP 或邮箱的失败记录，解除锁定
+func ClearLoginThrottle(c *gin.Context) {
+	var req struct {
+		Scope string `json:"scope"` // ip 或 email
+		Value string `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Value = strings.TrimSpace(req.Value)
+	if req.Scope != models.LoginThrottleScopeIP && req.Scope != models.LoginThrottleScopeEmail {
+		c.JSON(400, gin.H{"error": "scope 只能是 ip 或 email"})
+		return
+	}
+	if req.Value == "" {
+		c.JSON(400, gin.H{"error": "value 不能为空"})
+		return
+	}
+	if req.Scope == models.LoginThrottleScopeEmail {
+		req.Value = strings.ToLower(req.Value)
+	}
+
+	var record models.LoginThrottle
+	if err := database.DB.Where("scope = ? AND value = ?", req.Scope, req.Value).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": "未找到登录失败记录"})
+		} else {
+			c.JSON(500, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if err := tx.Delete(&record).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "清除登录失败记录失败"})
+		return
+	}
+
+	before := gin.H{"scope": record.Scope, "value": record.Value, "failures": record.Failures, "locked_until": record.LockedUntil}
+	if err := writeAuditLog(c, tx, AuditClearLoginThrottle, AuditTargetLoginThrottle, record.ID, "", before, nil); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": "清除登录失败记录失败"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "已解除锁定"})
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"difyserver/database"
+	"difyserver/middleware"
+	"difyserver/models"
+	"difyserver/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// RefreshToken 使用刷新 token 换取新的访问 token 和刷新 token，旧的刷新 token 随即失效
+func RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RefreshToken == "" {
+		c.JSON(400, gin.H{"error": "refresh_token 不能为空"})
+		return
+	}
+
+	claims, err := utils.ParseToken(req.RefreshToken)
+	if err != nil || claims.Type != utils.TokenTypeRefresh {
+		c.JSON(401, gin.H{"error": "无效的刷新 token"})
+		return
+	}
+
+	revoked, err := middleware.IsTokenRevoked(claims)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if revoked {
+		c.JSON(401, gin.H{"error": "刷新 token 已失效，请重新登录"})
+		return
+	}
+
+	active, err := middleware.AccountActive(claims.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !active {
+		c.JSON(401, gin.H{"error": "账号已被停用"})
+		return
+	}
+
+	// 重新获取角色，已被移除的管理员不能再刷新
+	role, err := middleware.ResolveAdminRole(claims.ID, claims.Email)
+	if err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if role == "" {
+		c.JSON(401, gin.H{"error": "没有管理员权限"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	// jti 唯一，同一刷新 token 并发刷新时只有一次能写入吊销记录
+	revokedNow, err := revokeToken(tx, claims, "refresh")
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+	if !revokedNow {
+		tx.Rollback()
+		c.JSON(401, gin.H{"error": "刷新 token 已失效，请重新登录"})
+		return
+	}
+
+	token, err := utils.GenerateToken(claims.ID, claims.Email, role)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "生成token失败"})
+		return
+	}
+	refreshToken, err := utils.GenerateRefreshToken(claims.ID, claims.Email, role)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "生成token失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"role":          role,
+		"token":         token,
+		"refresh_token": refreshToken,
+	})
+}
+
+// Logout 吊销当前访问 token，同时传入刷新 token 时一并吊销
+func Logout(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	_ = c.ShouldBindJSON(&req)
+
+	tx := database.DB.Begin()
+
+	if claims, ok := c.Get("tokenClaims"); ok {
+		if _, err := revokeToken(tx, claims.(*utils.Claims), "logout"); err != nil {
+			tx.Rollback()
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 只吊销属于当前用户的刷新 token
+	if req.RefreshToken != "" {
+		claims, err := utils.ParseToken(req.RefreshToken)
+		if err == nil && claims.Type == utils.TokenTypeRefresh && claims.ID == c.GetString("userID") {
+			if _, err := revokeToken(tx, claims, "logout"); err != nil {
+				tx.Rollback()
+				c.JSON(500, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "退出登录成功"})
+}
+
+// RevokeAccountTokens 吊销指定账号已签发的所有 token，用于 token 泄露等场景
+func RevokeAccountTokens(c *gin.Context) {
+	var req struct {
+		AccountID string `json:"account_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AccountID == "" {
+		c.JSON(400, gin.H{"error": "account_id 不能为空"})
+		return
+	}
+
+	// 开启事务
+	tx := database.DB.Begin()
+
+	if err := revokeAccountTokens(tx, req.AccountID, "revoke_all"); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := writeAuditLog(c, tx, AuditRevokeAccountTokens, AuditTargetAccount, req.AccountID, "", nil, gin.H{"revoked": true}); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "写入审计日志失败"})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "已吊销该用户的所有登录凭证"})
+}
+
+// revokeAccountTokens 吊销账号在此之前签发的所有 token，用于停用账号、重置密码等场景
+func revokeAccountTokens(db *gorm.DB, accountID, reason string) error {
+	revoked := models.RevokedToken{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Reason:    reason,
+		// 刷新 token 的有效期最长，超过后该账号之前签发的 token 都已过期
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL()),
+		CreatedAt: time.Now(),
+	}
+	return db.Create(&revoked).Error
+}
+
+// revokeToken 将单个 token 加入吊销列表，并顺便清理已过期的吊销记录
+// 该 token 已在吊销列表中时返回 false
+func revokeToken(db *gorm.DB, claims *utils.Claims, reason string) (bool, error) {
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{}).Error; err != nil {
+		return false, err
+	}
+
+	revoked := models.RevokedToken{
+		ID:        uuid.New().String(),
+		JTI:       claims.Id,
+		AccountID: claims.ID,
+		Reason:    reason,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		CreatedAt: time.Now(),
+	}
+	result := db.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "jti"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "jti <> ''"}}},
+		DoNothing:   true,
+	}).Create(&revoked)
+	return result.RowsAffected == 1, result.Error
+}
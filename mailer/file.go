@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// FileSender 把邮件保存为 .eml 文件，用于测试
+type FileSender struct {
+	from string
+	dir  string
+}
+
+func (s *FileSender) Send(msg Message) error {
+	if err := validRecipient(msg.To); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	// 邮件中包含激活链接，只允许当前用户读取
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102150405"), uuid.New().String())
+	return os.WriteFile(filepath.Join(s.dir, name), build(s.from, msg), 0o600)
+}
+
+// LogSender 把邮件输出到日志，用于测试
+// 激活链接中的 token 可以直接设置密码，输出前隐藏，避免日志中出现可用的凭据
+type LogSender struct{}
+
+// linkToken 匹配链接中的 token 参数
+var linkToken = regexp.MustCompile(`token=[^\s&]+`)
+
+func (s *LogSender) Send(msg Message) error {
+	if err := validRecipient(msg.To); err != nil {
+		return err
+	}
+	body := linkToken.ReplaceAllString(msg.Body, "token=***")
+	log.Printf("发送邮件 To: %s Subject: %s\n%s", msg.To, msg.Subject, body)
+	return nil
+}
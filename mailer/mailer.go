@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"bytes"
+	"difyserver/config"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender 邮件发送方式
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Default 根据配置初始化的发送方式
+var Default Sender
+
+// InitMailer 根据 mail 配置初始化邮件发送方式
+func InitMailer() error {
+	cfg := config.GlobalConfig.Mail
+	if cfg.From != "" {
+		if _, err := mail.ParseAddress(cfg.From); err != nil {
+			return fmt.Errorf("无效的发件人地址: %w", err)
+		}
+	}
+
+	switch cfg.Type {
+	case config.MailTypeLog:
+		log.Println("邮件发送方式为 log，邀请邮件只输出到日志且激活链接已隐藏，用户无法收到邀请")
+		Default = &LogSender{}
+	case config.MailTypeFile:
+		Default = &FileSender{from: cfg.From, dir: cfg.File.Path}
+	case config.MailTypeSMTP:
+		s, err := NewSMTPSender(cfg.From, cfg.SMTP)
+		if err != nil {
+			return err
+		}
+		Default = s
+	default:
+		return fmt.Errorf("不支持的邮件发送方式: %s", cfg.Type)
+	}
+	return nil
+}
+
+// build 生成 RFC 5322 格式的邮件内容，主题和正文使用 UTF-8 编码
+func build(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	// 正文按 76 个字符换行
+	body := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(body) > 76 {
+		buf.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	buf.WriteString(body + "\r\n")
+	return buf.Bytes()
+}
+
+// validRecipient 收件人只能是单个地址，防止在邮件头中注入其他字段
+func validRecipient(to string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("无效的收件人地址: %s", to)
+	}
+	if _, err := mail.ParseAddress(to); err != nil {
+		return fmt.Errorf("无效的收件人地址: %s", to)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"difyserver/config"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// SMTPSender 通过 SMTP 服务器发送邮件
+type SMTPSender struct {
+	from string
+	cfg  config.SMTPConfig
+}
+
+func NewSMTPSender(from string, cfg config.SMTPConfig) (*SMTPSender, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("smtp host 不能为空")
+	}
+	if from == "" {
+		return nil, errors.New("使用 smtp 发送邮件时 from 不能为空")
+	}
+	if cfg.TLS != "starttls" && cfg.TLS != "tls" && cfg.TLS != "none" {
+		return nil, fmt.Errorf("不支持的 smtp tls 方式: %s", cfg.TLS)
+	}
+	return &SMTPSender{from: from, cfg: cfg}, nil
+}
+
+func (s *SMTPSender) Send(msg Message) error {
+	if err := validRecipient(msg.To); err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(s.from)
+	to, _ := mail.ParseAddress(msg.To)
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if s.cfg.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if s.cfg.TLS == "starttls" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(build(s.from, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
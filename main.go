@@ -4,7 +4,10 @@ import (
 	"difyserver/config"
 	"difyserver/database"
 	"difyserver/handlers"
+	"difyserver/mailer"
 	"difyserver/middleware"
+	"difyserver/storage"
+	"difyserver/utils"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"log"
@@ -16,11 +19,28 @@ func main() {
 		log.Fatal("加载配置失败:", err)
 	}
 
+	if err := utils.InitJWT(); err != nil {
+		log.Fatal("加载 jwt 配置失败:", err)
+	}
+
+	if err := storage.InitStorage(); err != nil {
+		log.Fatal("初始化存储失败:", err)
+	}
+
+	if err := mailer.InitMailer(); err != nil {
+		log.Fatal("初始化邮件发送失败:", err)
+	}
+
 	if err := database.InitDB(); err != nil {
 		log.Fatal("数据库连接失败:", err)
 	}
 	r := gin.Default()
 
+	// 只信任配置的反向代理转发的客户端 IP，避免伪造 X-Forwarded-For 绕过登录失败限制
+	if err := r.SetTrustedProxies(config.GlobalConfig.TrustedProxies); err != nil {
+		log.Fatal("trusted_proxies 配置错误:", err)
+	}
+
 	// CORS 配置...
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000"},
@@ -39,25 +59,84 @@ func main() {
 	})
 	// API 路由...
 	r.POST("/api/login.json", handlers.Login)
+	r.POST("/api/refresh_token.json", handlers.RefreshToken)
+	// 邀请激活，被邀请的用户通过链接中的 token 设置密码
+	r.GET("/api/invitation.json", handlers.GetInvitation)
+	r.POST("/api/accept_invitation.json", handlers.AcceptInvitation)
+	// 管理员使用当前密码修改自己的密码，密码过期后也可以使用
+	r.POST("/api/change_password.json", handlers.ChangePassword)
 	auth := r.Group("/api")
 	auth.Use(middleware.AuthMiddleware())
 	{
-		auth.GET("/accounts.json", handlers.GetAccounts)
-		auth.POST("/add_account.json", handlers.AddAccount)
-		auth.POST("/del_account.json", handlers.DelAccount)
-		auth.GET("/tenants.json", handlers.GetTenants)
-		auth.POST("/add_tenant.json", handlers.AddTenant)
-		auth.GET("/datasets.json", handlers.GetDatasets)
-		auth.GET("/list_dataset_tenant.json", handlers.ListDatasetTenant)
-		auth.POST("/add_dataset_tenant.json", handlers.AddDatasetTenant)
-		auth.POST("/del_dataset_tenant.json", handlers.DelDatasetTenant)
-		auth.GET("/list_tenant_account.json", handlers.ListTenantAccount)
-		auth.GET("/list_tenant_account_by_account.json", handlers.ListTenantAccountByAccount)
-		auth.GET("/list_tenant_account_by_tenant.json", handlers.ListTenantAccountByTenant)
-		auth.POST("/add_tenant_account.json", handlers.AddTenantAccount)
-		auth.POST("/del_tenant_account.json", handlers.DelTenantAccount)
-		auth.POST("/update_tenant_account_role.json", handlers.UpdateTenantAccountRole)
-		auth.POST("/set_account_password.json", handlers.SetAccountPassword)
+		auth.POST("/logout.json", handlers.Logout)
+		auth.GET("/accounts.json", middleware.RequirePermission(middleware.PermRead), handlers.GetAccounts)
+		auth.POST("/add_account.json", middleware.RequirePermission(middleware.PermWrite), handlers.AddAccount)
+		auth.POST("/del_account.json", middleware.RequirePermission(middleware.PermWrite), handlers.DelAccount)
+		auth.GET("/tenants.json", middleware.RequirePermission(middleware.PermRead), handlers.GetTenants)
+		auth.POST("/add_tenant.json", middleware.RequirePermission(middleware.PermWrite), handlers.AddTenant)
+		auth.POST("/update_tenant.json", middleware.RequirePermission(middleware.PermWrite), handlers.UpdateTenant)
+		auth.POST("/set_tenant_plan.json", middleware.RequirePermission(middleware.PermWrite), handlers.SetTenantPlan)
+		auth.POST("/set_tenant_status.json", middleware.RequirePermission(middleware.PermWrite), handlers.SetTenantStatus)
+		auth.GET("/del_tenant_preview.json", middleware.RequirePermission(middleware.PermRead), handlers.PreviewDelTenant)
+		auth.POST("/del_tenant.json", middleware.RequirePermission(middleware.PermWrite), handlers.DelTenant)
+		auth.POST("/clone_tenant.json", middleware.RequirePermission(middleware.PermWrite), handlers.CloneTenant)
+		auth.GET("/datasets.json", middleware.RequirePermission(middleware.PermRead), handlers.GetDatasets)
+		auth.GET("/list_dataset_tenant.json", middleware.RequirePermission(middleware.PermRead), handlers.ListDatasetTenant)
+		auth.POST("/add_dataset_tenant.json", middleware.RequirePermission(middleware.PermWrite), handlers.AddDatasetTenant)
+		auth.POST("/del_dataset_tenant.json", middleware.RequirePermission(middleware.PermWrite), handlers.DelDatasetTenant)
+		auth.POST("/move_dataset.json", middleware.RequirePermission(middleware.PermWrite), handlers.MoveDataset)
+		auth.GET("/dataset_detail.json", middleware.RequirePermission(middleware.PermRead), handlers.GetDatasetDetail)
+		auth.GET("/documents.json", middleware.RequirePermission(middleware.PermRead), handlers.GetDocuments)
+		auth.GET("/document_segments.json", middleware.RequirePermission(middleware.PermRead), handlers.GetDocumentSegments)
+		auth.GET("/dataset_permission.json", middleware.RequirePermission(middleware.PermRead), handlers.GetDatasetPermission)
+		auth.POST("/set_dataset_permission.json", middleware.RequirePermission(middleware.PermWrite), handlers.SetDatasetPermission)
+		auth.GET("/apps.json", middleware.RequirePermission(middleware.PermRead), handlers.GetApps)
+		auth.POST("/move_app.json", middleware.RequirePermission(middleware.PermWrite), handlers.MoveApp)
+		auth.POST("/set_app_access.json", middleware.RequirePermission(middleware.PermWrite), handlers.SetAppAccess)
+		auth.GET("/api_tokens.json", middleware.RequirePermission(middleware.PermRead), handlers.GetAPITokens)
+		auth.POST("/add_api_token.json", middleware.RequirePermission(middleware.PermWrite), handlers.AddAPIToken)
+		auth.POST("/revoke_api_tokens.json", middleware.RequirePermission(middleware.PermWrite), handlers.RevokeAPITokens)
+		auth.GET("/providers.json", middleware.RequirePermission(middleware.PermRead), handlers.GetProviders)
+		auth.GET("/provider_models.json", middleware.RequirePermission(middleware.PermRead), handlers.GetProviderModels)
+		auth.GET("/tenant_default_models.json", middleware.RequirePermission(middleware.PermRead), handlers.GetTenantDefaultModels)
+		auth.POST("/set_tenant_default_model.json", middleware.RequirePermission(middleware.PermWrite), handlers.SetTenantDefaultModel)
+		auth.POST("/copy_provider.json", middleware.RequirePermission(middleware.PermWrite), handlers.CopyProvider)
+		auth.GET("/reports/messages.json", middleware.RequirePermission(middleware.PermRead), handlers.GetMessageReport)
+		auth.GET("/reports/workflow_runs.json", middleware.RequirePermission(middleware.PermRead), handlers.GetWorkflowRunReport)
+		auth.GET("/list_tenant_account.json", middleware.RequirePermission(middleware.PermRead), handlers.ListTenantAccount)
+		auth.GET("/list_tenant_account_by_account.json", middleware.RequirePermission(middleware.PermRead), handlers.ListTenantAccountByAccount)
+		auth.GET("/list_tenant_account_by_tenant.json", middleware.RequirePermission(middleware.PermRead), handlers.ListTenantAccountByTenant)
+		auth.GET("/tenant_summary.json", middleware.RequirePermission(middleware.PermRead), handlers.GetTenantSummary)
+		auth.POST("/add_tenant_account.json", middleware.RequirePermission(middleware.PermWrite), handlers.AddTenantAccount)
+		auth.POST("/del_tenant_account.json", middleware.RequirePermission(middleware.PermWrite), handlers.DelTenantAccount)
+		auth.POST("/update_tenant_account_role.json", middleware.RequirePermission(middleware.PermWrite), handlers.UpdateTenantAccountRole)
+		auth.POST("/transfer_tenant_owner.json", middleware.RequirePermission(middleware.PermWrite), handlers.TransferTenantOwner)
+		auth.GET("/check_tenant_consistency.json", middleware.RequirePermission(middleware.PermRead), handlers.CheckTenantConsistency)
+		auth.POST("/set_account_password.json", middleware.RequirePermission(middleware.PermWrite), handlers.SetAccountPassword)
+		auth.GET("/expired_passwords.json", middleware.RequirePermission(middleware.PermRead), handlers.GetExpiredPasswords)
+		auth.POST("/import_accounts.json", middleware.RequirePermission(middleware.PermWrite), handlers.ImportAccounts)
+		auth.POST("/update_account.json", middleware.RequirePermission(middleware.PermWrite), handlers.UpdateAccount)
+		auth.POST("/set_account_status.json", middleware.RequirePermission(middleware.PermWrite), handlers.SetAccountStatus)
+		auth.POST("/activate_account.json", middleware.RequirePermission(middleware.PermWrite), handlers.ActivateAccount)
+		auth.POST("/invite_account.json", middleware.RequirePermission(middleware.PermWrite), handlers.InviteAccount)
+		auth.POST("/resend_invitation.json", middleware.RequirePermission(middleware.PermWrite), handlers.ResendInvitation)
+		auth.POST("/revoke_invitation.json", middleware.RequirePermission(middleware.PermWrite), handlers.RevokeInvitation)
+		auth.GET("/invitations.json", middleware.RequirePermission(middleware.PermRead), handlers.GetInvitations)
+		// 数据导出，format=csv 或 ndjson
+		auth.GET("/export_accounts.json", middleware.RequirePermission(middleware.PermRead), handlers.ExportAccounts)
+		auth.GET("/export_tenants.json", middleware.RequirePermission(middleware.PermRead), handlers.ExportTenants)
+		auth.GET("/export_tenant_accounts.json", middleware.RequirePermission(middleware.PermRead), handlers.ExportTenantAccounts)
+		auth.GET("/export_datasets.json", middleware.RequirePermission(middleware.PermRead), handlers.ExportDatasets)
+		auth.GET("/audit_logs.json", middleware.RequirePermission(middleware.PermRead), handlers.GetAuditLogs)
+		// 管理员角色管理
+		auth.GET("/admin_roles.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.GetAdminRoles)
+		auth.POST("/set_admin_role.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.SetAdminRole)
+		auth.POST("/del_admin_role.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.DelAdminRole)
+		auth.POST("/revoke_account_tokens.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.RevokeAccountTokens)
+		auth.GET("/login_throttles.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.GetLoginThrottles)
+		auth.POST("/clear_login_throttle.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.ClearLoginThrottle)
+		auth.GET("/admin_tenants.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.GetAdminTenants)
+		auth.POST("/set_admin_tenants.json", middleware.RequirePermission(middleware.PermManageAdmins), handlers.SetAdminTenants)
 		//auth.POST("/api/login.json", handlers.Login)
 		if err := r.Run(":8080"); err != nil {
 			log.Fatal("服务启动失败:", err)
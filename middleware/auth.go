@@ -1,9 +1,12 @@
 package middleware
 
 import (
+	"difyserver/database"
+	"difyserver/models"
 	"difyserver/utils"
 	"github.com/gin-gonic/gin"
 	"strings"
+	"time"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -23,15 +26,74 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		claims, err := utils.ParseToken(parts[1])
-		if err != nil {
+		if err != nil || claims.Type != utils.TokenTypeAccess {
 			c.JSON(401, gin.H{"error": "无效的认证信息"})
 			c.Abort()
 			return
 		}
 
+		revoked, err := IsTokenRevoked(claims)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(401, gin.H{"error": "认证信息已失效，请重新登录"})
+			c.Abort()
+			return
+		}
+
+		// 停用或未激活的账号不能继续使用已签发的 token
+		active, err := AccountActive(claims.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(401, gin.H{"error": "账号已被停用"})
+			c.Abort()
+			return
+		}
+
+		// 每次请求都重新获取角色，移除管理员或调整角色后立即生效
+		role, err := ResolveAdminRole(claims.ID, claims.Email)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if role == "" {
+			c.JSON(401, gin.H{"error": "没有管理员权限"})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("userID", claims.ID)
 		c.Set("userEmail", claims.Email)
+		c.Set("userRole", role)
+		c.Set("tokenClaims", claims)
 		c.Next()
 	}
 }
+
+// IsTokenRevoked 检查 token 是否已被单独吊销，或其所属账号在签发之后被整体吊销
+func IsTokenRevoked(claims *utils.Claims) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.RevokedToken{}).
+		Where("jti = ? OR (jti = '' AND account_id = ? AND created_at >= ?)",
+			claims.Id, claims.ID, time.Unix(claims.IssuedAt, 0)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AccountActive 判断账号是否存在且状态为 active
+func AccountActive(accountID string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.Account{}).
+		Where("id = ? AND status = ?", accountID, models.AccountStatusActive).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"difyserver/config"
+	"difyserver/database"
+	"difyserver/models"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// 接口权限
+const (
+	PermRead         = "read"          // 查看数据
+	PermWrite        = "write"         // 修改用户、工作空间、知识库等数据
+	PermManageAdmins = "manage_admins" // 管理后台管理员及其角色
+)
+
+// rolePermissions 角色与权限的对应关系
+var rolePermissions = map[string]map[string]bool{
+	models.AdminRoleSuperAdmin: {
+		PermRead:         true,
+		PermWrite:        true,
+		PermManageAdmins: true,
+	},
+	models.AdminRoleTenantAdmin: {
+		PermRead:  true,
+		PermWrite: true,
+	},
+	models.AdminRoleAuditor: {
+		PermRead: true,
+	},
+}
+
+// HasPermission 判断角色是否拥有指定权限
+func HasPermission(role, perm string) bool {
+	return rolePermissions[role][perm]
+}
+
+// RequirePermission 校验当前登录用户的角色是否拥有指定权限，需在 AuthMiddleware 之后使用
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasPermission(c.GetString("userRole"), perm) {
+			c.JSON(403, gin.H{"error": "没有权限执行该操作"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// ResolveAdminRole 获取账号当前的管理后台角色，未配置角色时返回空字符串
+// config.yaml 中 admins 列出的邮箱视为超级管理员，用于初始化第一个管理员
+func ResolveAdminRole(accountID, email string) (string, error) {
+	var adminRole models.AdminRole
+	err := database.DB.Where("account_id = ?", accountID).First(&adminRole).Error
+	if err == nil {
+		if models.ValidAdminRoles[adminRole.Role] {
+			return adminRole.Role, nil
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	// 邮箱比较不区分大小写，与 handlers 中判断管理员账号的逻辑一致
+	for _, adminEmail := range config.GlobalConfig.Admins {
+		if strings.EqualFold(adminEmail, email) {
+			return models.AdminRoleSuperAdmin, nil
+		}
+	}
+
+	return "", nil
+}
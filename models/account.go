@@ -0,0 +1,47 @@
+package models
+
+// 用户状态，与 Dify 的 AccountStatus 保持一致
+const (
+	AccountStatusPending       = "pending"
+	AccountStatusUninitialized = "uninitialized"
+	AccountStatusActive        = "active"
+	AccountStatusBanned        = "banned"
+	AccountStatusClosed        = "closed"
+)
+
+// ValidAccountStatuses 管理后台可以设置的用户状态
+var ValidAccountStatuses = map[string]bool{
+	AccountStatusActive: true,
+	AccountStatusBanned: true,
+	AccountStatusClosed: true,
+}
+
+// ValidInterfaceLanguages Dify 支持的界面语言
+var ValidInterfaceLanguages = map[string]bool{
+	"en-US":   true,
+	"zh-Hans": true,
+	"zh-Hant": true,
+	"pt-BR":   true,
+	"es-ES":   true,
+	"fr-FR":   true,
+	"de-DE":   true,
+	"ja-JP":   true,
+	"ko-KR":   true,
+	"ru-RU":   true,
+	"it-IT":   true,
+	"uk-UA":   true,
+	"vi-VN":   true,
+	"pl-PL":   true,
+	"ro-RO":   true,
+	"tr-TR":   true,
+	"fa-IR":   true,
+	"sl-SI":   true,
+	"th-TH":   true,
+	"hi-IN":   true,
+}
+
+// ValidInterfaceThemes Dify 支持的界面主题
+var ValidInterfaceThemes = map[string]bool{
+	"light": true,
+	"dark":  true,
+}
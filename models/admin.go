@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// 管理后台角色
+const (
+	AdminRoleSuperAdmin  = "super_admin"  // 超级管理员：全部权限，可管理其他管理员
+	AdminRoleTenantAdmin = "tenant_admin" // 工作空间管理员：可查看和修改用户、工作空间
+	AdminRoleAuditor     = "auditor"      // 审计员：只读
+)
+
+// ValidAdminRoles 所有有效的管理后台角色
+var ValidAdminRoles = map[string]bool{
+	AdminRoleSuperAdmin:  true,
+	AdminRoleTenantAdmin: true,
+	AdminRoleAuditor:     true,
+}
+
+// AdminRole DifyServer 自有的管理员角色表，不属于 Dify 原有表结构
+type AdminRole struct {
+	ID        string `gorm:"primaryKey"`
+	AccountID string `gorm:"uniqueIndex"`
+	Email     string
+	Role      string
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (AdminRole) TableName() string {
+	return "difyserver_admin_roles"
+}
+
+// AdminTenant 管理员可管理的工作空间，工作空间管理员只能操作绑定的工作空间
+type AdminTenant struct {
+	ID        string `gorm:"primaryKey"`
+	AccountID string `gorm:"uniqueIndex:idx_admin_tenant"`
+	TenantID  string `gorm:"uniqueIndex:idx_admin_tenant"`
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+func (AdminTenant) TableName() string {
+	return "difyserver_admin_tenants"
+}
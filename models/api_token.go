@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// API 密钥类型，与 Dify 一致
+const (
+	APITokenTypeApp     = "app"
+	APITokenTypeDataset = "dataset"
+)
+
+// APIToken Dify 的 API 密钥，应用密钥属于单个应用，知识库密钥属于整个工作空间
+// Dify 不记录密钥的创建人，通过 DifyServer 创建的密钥可以在审计日志中查到创建人
+type APIToken struct {
+	ID         string `gorm:"primaryKey"`
+	AppID      *string
+	TenantID   *string
+	Type       string
+	Token      string
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// APITokenResponse API 密钥，Token 已脱敏，只有创建时返回完整的密钥
+type APITokenResponse struct {
+	ID         string
+	AppID      *string
+	AppName    string
+	TenantID   *string
+	TenantName string
+	Type       string
+	Token      string
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func NewAPITokenResponse(t APIToken) APITokenResponse {
+	return APITokenResponse{
+		ID:         t.ID,
+		AppID:      t.AppID,
+		TenantID:   t.TenantID,
+		Type:       t.Type,
+		Token:      MaskAPIToken(t.Token),
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// MaskAPIToken 只保留前缀和最后 4 位，例如 app-****************abcd
+func MaskAPIToken(token string) string {
+	prefix := ""
+	if i := strings.Index(token, "-"); i >= 0 {
+		prefix = token[:i+1]
+	}
+	rest := token[len(prefix):]
+	if len(rest) <= 4 {
+		return prefix + strings.Repeat("*", len(rest))
+	}
+	return prefix + strings.Repeat("*", len(rest)-4) + rest[len(rest)-4:]
+}
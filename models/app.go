@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+// App Dify 的应用
+type App struct {
+	ID               string `gorm:"primaryKey"`
+	TenantID         string
+	Name             string
+	Description      string
+	Mode             string
+	Icon             *string
+	IconBackground   *string
+	AppModelConfigID *string
+	WorkflowID       *string
+	Status           string
+	EnableSite       bool
+	EnableAPI        bool `gorm:"column:enable_api"`
+	APIRpm           int  `gorm:"column:api_rpm"`
+	APIRph           int  `gorm:"column:api_rph"`
+	IsDemo           bool
+	IsPublic         bool
+	CreatedBy        *string
+	CreatedAt        time.Time
+	UpdatedBy        *string
+	UpdatedAt        time.Time
+}
+
+// AppResponse 应用列表字段，附带所属工作空间名称和创建人邮箱
+type AppResponse struct {
+	ID             string
+	TenantID       string
+	TenantName     string
+	Name           string
+	Description    string
+	Mode           string
+	Status         string
+	EnableSite     bool
+	EnableAPI      bool
+	CreatedBy      *string
+	CreatedByEmail string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func NewAppResponse(a App) AppResponse {
+	return AppResponse{
+		ID:          a.ID,
+		TenantID:    a.TenantID,
+		Name:        a.Name,
+		Description: a.Description,
+		Mode:        a.Mode,
+		Status:      a.Status,
+		EnableSite:  a.EnableSite,
+		EnableAPI:   a.EnableAPI,
+		CreatedBy:   a.CreatedBy,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
+	}
+}
+
+// AppMoveReport 迁移应用到其他工作空间的结果
+type AppMoveReport struct {
+	AppID         string
+	FromTenantID  string
+	ToTenantID    string
+	DryRun        bool
+	Changes       []TableChange
+	SkippedTables []string // 当前 Dify 版本中不存在的表
+}
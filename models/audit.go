@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditLog 管理操作审计日志，记录每一次修改操作的操作人、对象和前后数据
+type AuditLog struct {
+	ID         string `gorm:"primaryKey"`
+	ActorID    string `gorm:"index"`
+	ActorEmail string `gorm:"index"`
+	Action     string `gorm:"index"`
+	TargetType string
+	TargetID   string `gorm:"index"`
+	TenantID   string `gorm:"index"`
+	Before     string `gorm:"type:text"` // 修改前数据，JSON 格式
+	After      string `gorm:"type:text"` // 修改后数据，JSON 格式
+	ClientIP   string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (AuditLog) TableName() string {
+	return "difyserver_audit_logs"
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// TableChange 迁移知识库、应用时对一张表的修改
+type TableChange struct {
+	Table  string
+	Action string // update 或 delete
+	Rows   int64
+	Note   string
+}
+
+// DatasetMoveReport 迁移知识库到其他工作空间的结果
+type DatasetMoveReport struct {
+	DatasetID     string
+	FromTenantID  string
+	ToTenantID    string
+	DryRun        bool
+	Changes       []TableChange
+	SkippedTables []string // 当前 Dify 版本中不存在的表
+}
+
+// 知识库的可见范围，与 Dify 的 DatasetPermissionEnum 一致
+const (
+	DatasetPermissionOnlyMe         = "only_me"
+	DatasetPermissionAllTeamMembers = "all_team_members"
+	DatasetPermissionPartialMembers = "partial_members"
+)
+
+// ValidDatasetPermissions 所有有效的知识库可见范围
+var ValidDatasetPermissions = map[string]bool{
+	DatasetPermissionOnlyMe:         true,
+	DatasetPermissionAllTeamMembers: true,
+	DatasetPermissionPartialMembers: true,
+}
+
+// DatasetPermission 可见范围为 partial_members 时可以访问知识库的成员
+type DatasetPermission struct {
+	ID            string `gorm:"primaryKey"`
+	DatasetID     string
+	AccountID     string
+	TenantID      string
+	HasPermission bool
+	CreatedAt     time.Time
+}
+
+// DatasetPermissionMember 可以访问知识库的成员
+type DatasetPermissionMember struct {
+	AccountID    string
+	AccountEmail string
+	AccountName  string
+	Role         string // 在知识库所属工作空间中的角色，不是成员时为空
+}
+
+// DatasetPermissionResponse 知识库的可见范围及部分成员列表
+type DatasetPermissionResponse struct {
+	DatasetID  string
+	TenantID   string
+	Permission string
+	CreatedBy  string
+	Members    []DatasetPermissionMember
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// Document Dify 知识库中的文档，只读
+type Document struct {
+	ID                  string `gorm:"primaryKey"`
+	TenantID            string
+	DatasetID           string
+	Position            int
+	DataSourceType      string
+	DataSourceInfo      *string
+	Batch               string
+	Name                string
+	CreatedFrom         string
+	CreatedBy           string
+	CreatedAt           time.Time
+	ProcessingStartedAt *time.Time
+	WordCount           *int
+	Tokens              *int
+	IndexingLatency     *float64
+	CompletedAt         *time.Time
+	IsPaused            *bool
+	Error               *string
+	StoppedAt           *time.Time
+	IndexingStatus      string
+	Enabled             bool
+	DisabledAt          *time.Time
+	Archived            bool
+	ArchivedReason      *string
+	ArchivedAt          *time.Time
+	UpdatedAt           time.Time
+	DocType             *string
+	DocForm             string
+	DocLanguage         *string
+}
+
+// DocumentSegment Dify 文档的分段，只读
+type DocumentSegment struct {
+	ID          string `gorm:"primaryKey"`
+	TenantID    string
+	DatasetID   string
+	DocumentID  string
+	Position    int
+	Content     string
+	Answer      *string
+	WordCount   int
+	Tokens      int
+	Keywords    *string
+	IndexNodeID *string
+	HitCount    int
+	Enabled     bool
+	DisabledAt  *time.Time
+	Status      string
+	CreatedBy   string
+	CreatedAt   time.Time
+	IndexingAt  *time.Time
+	CompletedAt *time.Time
+	Error       *string
+	StoppedAt   *time.Time
+	UpdatedAt   time.Time
+}
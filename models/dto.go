@@ -0,0 +1,266 @@
+package models
+
+import (
+	"time"
+)
+
+// 接口返回给前端的数据结构，只包含明确允许返回的字段，不直接返回数据库模型
+
+// AccountResponse 用户信息，不包含密码和密码盐
+type AccountResponse struct {
+	ID                string
+	Name              string
+	Email             string
+	Avatar            string
+	InterfaceLanguage string
+	InterfaceTheme    string
+	Timezone          string
+	Status            string
+	HasPassword       bool // 是否已设置密码
+	TenantCount       int64
+	InitializedAt     *time.Time
+	LastLoginAt       *time.Time
+	LastLoginIP       *string
+	LastActiveAt      time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func NewAccountResponse(a Account) AccountResponse {
+	return AccountResponse{
+		ID:                a.ID,
+		Name:              a.Name,
+		Email:             a.Email,
+		Avatar:            a.Avatar,
+		InterfaceLanguage: a.InterfaceLanguage,
+		InterfaceTheme:    a.InterfaceTheme,
+		Timezone:          a.Timezone,
+		Status:            a.Status,
+		HasPassword:       a.Password != "",
+		InitializedAt:     a.InitializedAt,
+		LastLoginAt:       a.LastLoginAt,
+		LastLoginIP:       a.LastLoginIP,
+		LastActiveAt:      a.LastActiveAt,
+		CreatedAt:         a.CreatedAt,
+		UpdatedAt:         a.UpdatedAt,
+	}
+}
+
+// TenantResponse 工作空间信息
+type TenantResponse struct {
+	ID           string
+	Name         string
+	Plan         string
+	Status       string
+	CustomConfig *string
+	MemberCount  int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func NewTenantResponse(t Tenant) TenantResponse {
+	return TenantResponse{
+		ID:           t.ID,
+		Name:         t.Name,
+		Plan:         t.Plan,
+		Status:       t.Status,
+		CustomConfig: t.CustomConfig,
+		CreatedAt:    t.CreatedAt,
+		UpdatedAt:    t.UpdatedAt,
+	}
+}
+
+// TenantAccountJoinResponse 用户与工作空间的关联关系
+type TenantAccountJoinResponse struct {
+	ID        string
+	TenantID  string
+	AccountID string
+	Role      string
+	InvitedBy *string
+	Current   bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func NewTenantAccountJoinResponse(j TenantAccountJoin) TenantAccountJoinResponse {
+	return TenantAccountJoinResponse{
+		ID:        j.ID,
+		TenantID:  j.TenantID,
+		AccountID: j.AccountID,
+		Role:      j.Role,
+		InvitedBy: j.InvitedBy,
+		Current:   j.Current,
+		CreatedAt: j.CreatedAt,
+		UpdatedAt: j.UpdatedAt,
+	}
+}
+
+// DatasetResponse 知识库信息
+type DatasetResponse struct {
+	ID                     string
+	TenantID               string
+	Name                   string
+	Description            string
+	Provider               string
+	Permission             string
+	DataSourceType         string
+	IndexingTechnique      string
+	EmbeddingModel         string
+	EmbeddingModelProvider string
+	CreatedBy              string
+	CreatedAt              time.Time
+	UpdatedBy              string
+	UpdatedAt              time.Time
+}
+
+func NewDatasetResponse(d Dataset) DatasetResponse {
+	return DatasetResponse{
+		ID:                     d.ID,
+		TenantID:               d.TenantID,
+		Name:                   d.Name,
+		Description:            d.Description,
+		Provider:               d.Provider,
+		Permission:             d.Permission,
+		DataSourceType:         d.DataSourceType,
+		IndexingTechnique:      d.IndexingTechnique,
+		EmbeddingModel:         d.EmbeddingModel,
+		EmbeddingModelProvider: d.EmbeddingModelProvider,
+		CreatedBy:              d.CreatedBy,
+		CreatedAt:              d.CreatedAt,
+		UpdatedBy:              d.UpdatedBy,
+		UpdatedAt:              d.UpdatedAt,
+	}
+}
+
+// MembershipResponse 成员关系及其用户、工作空间信息，由联表查询得到
+type MembershipResponse struct {
+	ID            string
+	TenantID      string
+	AccountID     string
+	Role          string
+	InvitedBy     *string
+	Current       bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	AccountEmail  string
+	AccountName   string
+	AccountStatus string
+	TenantName    string
+	TenantPlan    string
+	TenantStatus  string
+}
+
+// TenantSummaryResponse 工作空间概况
+type TenantSummaryResponse struct {
+	Tenant        TenantResponse
+	MemberCount   int64
+	MembersByRole map[string]int64
+	DatasetCount  int64
+}
+
+// DocumentResponse 文档的列表字段，不包含 data_source_info 等内部字段
+type DocumentResponse struct {
+	ID             string
+	TenantID       string
+	DatasetID      string
+	Position       int
+	Name           string
+	DataSourceType string
+	CreatedFrom    string
+	IndexingStatus string
+	WordCount      *int
+	Tokens         *int
+	Error          *string
+	Enabled        bool
+	Archived       bool
+	ArchivedReason *string
+	IsPaused       *bool
+	DocForm        string
+	DocLanguage    *string
+	CreatedBy      string
+	CreatedAt      time.Time
+	CompletedAt    *time.Time
+	StoppedAt      *time.Time
+	UpdatedAt      time.Time
+	SegmentCount   int64
+}
+
+func NewDocumentResponse(d Document) DocumentResponse {
+	return DocumentResponse{
+		ID:             d.ID,
+		TenantID:       d.TenantID,
+		DatasetID:      d.DatasetID,
+		Position:       d.Position,
+		Name:           d.Name,
+		DataSourceType: d.DataSourceType,
+		CreatedFrom:    d.CreatedFrom,
+		IndexingStatus: d.IndexingStatus,
+		WordCount:      d.WordCount,
+		Tokens:         d.Tokens,
+		Error:          d.Error,
+		Enabled:        d.Enabled,
+		Archived:       d.Archived,
+		ArchivedReason: d.ArchivedReason,
+		IsPaused:       d.IsPaused,
+		DocForm:        d.DocForm,
+		DocLanguage:    d.DocLanguage,
+		CreatedBy:      d.CreatedBy,
+		CreatedAt:      d.CreatedAt,
+		CompletedAt:    d.CompletedAt,
+		StoppedAt:      d.StoppedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
+
+// DocumentSegmentResponse 文档分段
+type DocumentSegmentResponse struct {
+	ID          string
+	DocumentID  string
+	Position    int
+	Content     string
+	Answer      *string
+	WordCount   int
+	Tokens      int
+	Keywords    *string
+	HitCount    int
+	Enabled     bool
+	Status      string
+	Error       *string
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+	UpdatedAt   time.Time
+}
+
+func NewDocumentSegmentResponse(s DocumentSegment) DocumentSegmentResponse {
+	return DocumentSegmentResponse{
+		ID:          s.ID,
+		DocumentID:  s.DocumentID,
+		Position:    s.Position,
+		Content:     s.Content,
+		Answer:      s.Answer,
+		WordCount:   s.WordCount,
+		Tokens:      s.Tokens,
+		Keywords:    s.Keywords,
+		HitCount:    s.HitCount,
+		Enabled:     s.Enabled,
+		Status:      s.Status,
+		Error:       s.Error,
+		CreatedAt:   s.CreatedAt,
+		CompletedAt: s.CompletedAt,
+		UpdatedAt:   s.UpdatedAt,
+	}
+}
+
+// DatasetDetailResponse 知识库详情，包含文档和分段的统计，用于排查检索不到内容的问题
+type DatasetDetailResponse struct {
+	Dataset               DatasetResponse
+	DocumentCount         int64
+	DocumentsByStatus     map[string]int64 // 按 indexing_status 统计
+	DisabledDocuments     int64
+	ArchivedDocuments     int64
+	ErrorDocuments        int64
+	SegmentCount          int64
+	EnabledSegmentCount   int64
+	CompletedSegmentCount int64
+	WordCount             int64
+}
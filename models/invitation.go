@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+// 邀请状态，过期的邀请仍为 pending，返回时根据 ExpiresAt 显示为 expired
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusRevoked  = "revoked"
+	InvitationStatusExpired  = "expired"
+)
+
+// Invitation 用户邀请，邀请链接中的 token 以该记录的 ID 作为 jti，接受或撤销后链接失效
+type Invitation struct {
+	ID         string `gorm:"primaryKey"`
+	AccountID  string `gorm:"index"`
+	TenantID   string `gorm:"index"` // 邀请加入的工作空间，只用于邮件和激活页面展示，可为空
+	Email      string
+	InvitedBy  string
+	Status     string `gorm:"index"`
+	ExpiresAt  time.Time
+	SentAt     *time.Time // 邮件发送成功的时间
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (Invitation) TableName() string {
+	return "difyserver_invitations"
+}
+
+// InvitationResponse 邀请记录，附带工作空间名称
+type InvitationResponse struct {
+	ID         string
+	AccountID  string
+	Email      string
+	TenantID   string
+	TenantName string
+	InvitedBy  string
+	Status     string
+	ExpiresAt  time.Time
+	SentAt     *time.Time
+	AcceptedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func NewInvitationResponse(i Invitation) InvitationResponse {
+	status := i.Status
+	if status == InvitationStatusPending && time.Now().After(i.ExpiresAt) {
+		status = InvitationStatusExpired
+	}
+	return InvitationResponse{
+		ID:         i.ID,
+		AccountID:  i.AccountID,
+		Email:      i.Email,
+		TenantID:   i.TenantID,
+		InvitedBy:  i.InvitedBy,
+		Status:     status,
+		ExpiresAt:  i.ExpiresAt,
+		SentAt:     i.SentAt,
+		AcceptedAt: i.AcceptedAt,
+		CreatedAt:  i.CreatedAt,
+	}
+}
+
+// InvitationInfo 激活页面展示的邀请信息，公开接口返回，不包含其他敏感字段
+type InvitationInfo struct {
+	Email      string
+	Name       string
+	TenantName string
+	ExpiresAt  time.Time
+}
@@ -5,16 +5,22 @@ import (
 )
 
 type Account struct {
-	ID                string `json:"ID"`
-	Name              string `json:"Name"`
-	Email             string `json:"Email"`
-	Password          string `json:"Password"`
-	PasswordSalt      string `json:"PasswordSalt"`
-	Avatar            string `json:"Avatar"`
-	InterfaceLanguage string `json:"InterfaceLanguage"`
-	InterfaceTheme    string `json:"InterfaceTheme"`
-	Timezone          string `json:"Timezone"`
-	Status            string `json:"Status"`
+	ID                string     `json:"ID"`
+	Name              string     `json:"Name"`
+	Email             string     `json:"Email"`
+	Password          string     `json:"Password"`
+	PasswordSalt      string     `json:"PasswordSalt"`
+	Avatar            string     `json:"Avatar"`
+	InterfaceLanguage string     `json:"InterfaceLanguage"`
+	InterfaceTheme    string     `json:"InterfaceTheme"`
+	Timezone          string     `json:"Timezone"`
+	Status            string     `json:"Status"`
+	InitializedAt     *time.Time `json:"InitializedAt"`
+	LastLoginAt       *time.Time `json:"LastLoginAt"`
+	LastLoginIP       *string    `json:"LastLoginIP"`
+	LastActiveAt      time.Time  `json:"LastActiveAt" gorm:"default:CURRENT_TIMESTAMP"`
+	CreatedAt         time.Time  `json:"CreatedAt"`
+	UpdatedAt         time.Time  `json:"UpdatedAt"`
 }
 
 type Tenant struct {
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// PasswordHistory 通过 DifyServer 设置过的密码，用于检查密码重复使用和密码有效期
+// 与 accounts 表相同，保存的是 PBKDF2 加密后的密码和盐值
+type PasswordHistory struct {
+	ID           string `gorm:"primaryKey"`
+	AccountID    string `gorm:"index"`
+	Password     string
+	PasswordSalt string
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "difyserver_password_histories"
+}
+
+// PasswordViolation 密码不满足的一条安全策略规则
+type PasswordViolation struct {
+	Rule    string // min_length、require_letter、require_upper、require_lower、require_digit、require_symbol、denylist、history
+	Message string
+}
+
+// PasswordExpiryResponse 密码已过期的用户
+type PasswordExpiryResponse struct {
+	ID                string
+	Email             string
+	Name              string
+	Status            string
+	PasswordChangedAt time.Time // 当前密码的设置时间，取最近一条密码历史
+	ExpiredAt         time.Time
+}
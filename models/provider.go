@@ -0,0 +1,142 @@
+package models
+
+import (
+	"time"
+)
+
+// 供应商类型，与 Dify 一致
+const (
+	ProviderTypeCustom = "custom"
+	ProviderTypeSystem = "system"
+)
+
+// ModelTypes 接口使用的模型类型 -> Dify 数据库中保存的模型类型
+var ModelTypes = map[string]string{
+	"llm":            "text-generation",
+	"text-embedding": "embeddings",
+	"rerank":         "reranking",
+	"speech2text":    "speech2text",
+	"tts":            "tts",
+	"moderation":     "moderation",
+}
+
+// Provider Dify 工作空间配置的模型供应商，EncryptedConfig 为 JSON，其中的密钥字段用工作空间的 RSA 公钥加密
+type Provider struct {
+	ID              string `gorm:"primaryKey"`
+	TenantID        string
+	ProviderName    string
+	ProviderType    string
+	EncryptedConfig *string
+	IsValid         bool
+	LastUsed        *time.Time
+	QuotaType       *string
+	QuotaLimit      *int64
+	QuotaUsed       *int64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// ProviderModel Dify 中单独配置了凭据的模型
+type ProviderModel struct {
+	ID              string `gorm:"primaryKey"`
+	TenantID        string
+	ProviderName    string
+	ModelName       string
+	ModelType       string
+	EncryptedConfig *string
+	IsValid         bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// TenantDefaultModel 工作空间各类型的默认模型
+type TenantDefaultModel struct {
+	ID           string `gorm:"primaryKey"`
+	TenantID     string
+	ProviderName string
+	ModelName    string
+	ModelType    string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// TenantPreferredModelProvider 工作空间对某个供应商优先使用系统配额还是自定义凭据
+type TenantPreferredModelProvider struct {
+	ID                    string `gorm:"primaryKey"`
+	TenantID              string
+	ProviderName          string
+	PreferredProviderType string
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+}
+
+// ProviderResponse 模型供应商，Credentials 中加密的字段已脱敏
+type ProviderResponse struct {
+	ID           string
+	TenantID     string
+	TenantName   string
+	ProviderName string
+	ProviderType string
+	Credentials  map[string]interface{}
+	IsValid      bool
+	LastUsed     *time.Time
+	QuotaType    *string
+	QuotaLimit   *int64
+	QuotaUsed    *int64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func NewProviderResponse(p Provider) ProviderResponse {
+	return ProviderResponse{
+		ID:           p.ID,
+		TenantID:     p.TenantID,
+		ProviderName: p.ProviderName,
+		ProviderType: p.ProviderType,
+		IsValid:      p.IsValid,
+		LastUsed:     p.LastUsed,
+		QuotaType:    p.QuotaType,
+		QuotaLimit:   p.QuotaLimit,
+		QuotaUsed:    p.QuotaUsed,
+		CreatedAt:    p.CreatedAt,
+		UpdatedAt:    p.UpdatedAt,
+	}
+}
+
+// ProviderModelResponse 单独配置了凭据的模型，Credentials 中加密的字段已脱敏
+type ProviderModelResponse struct {
+	ID           string
+	TenantID     string
+	TenantName   string
+	ProviderName string
+	ModelName    string
+	ModelType    string
+	Credentials  map[string]interface{}
+	IsValid      bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func NewProviderModelResponse(m ProviderModel) ProviderModelResponse {
+	return ProviderModelResponse{
+		ID:           m.ID,
+		TenantID:     m.TenantID,
+		ProviderName: m.ProviderName,
+		ModelName:    m.ModelName,
+		ModelType:    m.ModelType,
+		IsValid:      m.IsValid,
+		CreatedAt:    m.CreatedAt,
+		UpdatedAt:    m.UpdatedAt,
+	}
+}
+
+// ProviderCopyReport 复制供应商配置的结果
+type ProviderCopyReport struct {
+	ProviderName   string
+	SourceTenantID string
+	TargetTenantID string
+	Providers      int // 复制的 providers 行数
+	ProviderModels int // 复制的 provider_models 行数
+	Preferred      bool
+	Replaced       bool // 是否覆盖了目标工作空间已有的配置
+}
@@ -0,0 +1,37 @@
+package models
+
+// MessageReportRow 按维度汇总的对话消息用量，不同币种分别汇总
+type MessageReportRow struct {
+	GroupKey      string
+	GroupName     string
+	Currency      string
+	MessageCount  int64
+	MessageTokens int64
+	AnswerTokens  int64
+	TotalTokens   int64
+	TotalPrice    float64
+	AvgLatency    float64 // 平均响应时间（秒）
+}
+
+// WorkflowRunReportRow 按维度汇总的工作流运行记录
+type WorkflowRunReportRow struct {
+	GroupKey         string
+	GroupName        string
+	RunCount         int64
+	SucceededCount   int64
+	FailedCount      int64
+	StoppedCount     int64
+	TotalTokens      int64
+	TotalSteps       int64
+	TotalElapsedTime float64 // 总运行时间（秒）
+	AvgElapsedTime   float64
+}
+
+// ReportResponse 用量报表
+type ReportResponse struct {
+	GroupBy  string
+	Start    string
+	End      string
+	Timezone string
+	Data     interface{}
+}
@@ -0,0 +1,87 @@
+package models
+
+// 工作空间状态，与 Dify 的 TenantStatus 保持一致
+const (
+	TenantStatusNormal  = "normal"
+	TenantStatusArchive = "archive"
+)
+
+// 工作空间成员角色，每个工作空间有且只有一个 owner
+const (
+	TenantRoleOwner  = "owner"
+	TenantRoleAdmin  = "admin"
+	TenantRoleEditor = "editor"
+	TenantRoleNormal = "normal"
+)
+
+// ValidTenantStatuses 所有有效的工作空间状态
+var ValidTenantStatuses = map[string]bool{
+	TenantStatusNormal:  true,
+	TenantStatusArchive: true,
+}
+
+// ValidTenantPlans 工作空间可设置的计划
+var ValidTenantPlans = map[string]bool{
+	"basic":        true,
+	"sandbox":      true,
+	"professional": true,
+	"team":         true,
+	"enterprise":   true,
+}
+
+// OrphanApp 删除工作空间后会失去归属的应用
+type OrphanApp struct {
+	ID   string
+	Name string
+	Mode string
+}
+
+// OrphanDataset 删除工作空间后会失去归属的知识库
+type OrphanDataset struct {
+	ID   string
+	Name string
+}
+
+// TenantDeletePreview 删除工作空间前的影响预览
+type TenantDeletePreview struct {
+	Tenant      TenantResponse
+	MemberCount int64
+	Datasets    []OrphanDataset
+	Apps        []OrphanApp
+	Deleted     []TableChange // 随工作空间一并删除的模型供应商、API 密钥、邀请等记录的行数
+}
+
+// ClonedObject 复制工作空间时复制的一个应用或知识库
+type ClonedObject struct {
+	SourceID string
+	ID       string
+	Name     string
+}
+
+// TenantCloneReport 复制工作空间的结果
+type TenantCloneReport struct {
+	Tenant         TenantResponse
+	SourceTenantID string
+	Members        int // 包括所有者
+	Providers      int
+	ProviderModels int
+	DefaultModels  int
+	Apps           []ClonedObject
+	Datasets       []ClonedObject
+	Notes          []string // 需要手动处理的问题，例如应用引用了没有复制的知识库
+}
+
+// ConsistencyIssue 成员关系不满足约束的一条记录
+type ConsistencyIssue struct {
+	Type      string // 问题类型，见 handlers 中的 issue* 常量
+	TenantID  string
+	AccountID string
+	Count     int64 // 相关记录数，例如 owner 数量、重复次数
+	Message   string
+}
+
+// ConsistencyReport 成员关系一致性检查结果
+type ConsistencyReport struct {
+	Total  int
+	Issues []ConsistencyIssue
+}
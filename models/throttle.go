@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// 登录失败的统计维度
+const (
+	LoginThrottleScopeIP    = "ip"
+	LoginThrottleScopeEmail = "email"
+)
+
+// LoginThrottle 按来源 IP 或邮箱统计的连续登录失败次数，LockedUntil 之前拒绝该 IP 或邮箱登录
+type LoginThrottle struct {
+	ID           string `gorm:"primaryKey"`
+	Scope        string `gorm:"uniqueIndex:idx_difyserver_login_throttles_key"` // ip 或 email
+	Value        string `gorm:"uniqueIndex:idx_difyserver_login_throttles_key"` // 来源 IP 或小写的邮箱
+	Failures     int
+	LastFailedAt *time.Time
+	LockedUntil  *time.Time
+	ExpiresAt    time.Time `gorm:"index"` // 超过该时间后记录可以清理
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (LoginThrottle) TableName() string {
+	return "difyserver_login_throttles"
+}
+
+// LoginThrottleResponse 登录失败记录，Locked 表示当前处于锁定或重试等待中，不能登录
+type LoginThrottleResponse struct {
+	ID           string
+	Scope        string
+	Value        string
+	Failures     int
+	Locked       bool
+	LastFailedAt *time.Time
+	LockedUntil  *time.Time
+	RetryAfter   int // 距离可以再次登录的秒数，0 表示当前可以登录
+	UpdatedAt    time.Time
+}
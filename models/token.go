@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// RevokedToken 已吊销的 token
+// JTI 不为空时吊销单个 token；JTI 为空时吊销该账号在 CreatedAt 之前签发的所有 token
+type RevokedToken struct {
+	ID        string `gorm:"primaryKey"`
+	JTI       string `gorm:"uniqueIndex:idx_difyserver_revoked_tokens_jti,where:jti <> ''"` // 同一 token 只能吊销一次，刷新 token 据此保证只能使用一次
+	AccountID string `gorm:"index"`
+	Reason    string
+	ExpiresAt time.Time `gorm:"index"` // 超过该时间后记录可以清理
+	CreatedAt time.Time
+}
+
+func (RevokedToken) TableName() string {
+	return "difyserver_revoked_tokens"
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage 本地文件系统存储，对应 Dify 的 STORAGE_TYPE=opendal（fs）或 local
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+func (s *LocalStorage) path(filename string) string {
+	return filepath.Join(s.root, filepath.FromSlash(filename))
+}
+
+func (s *LocalStorage) Save(filename string, data []byte) error {
+	path := s.path(filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (s *LocalStorage) Load(filename string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *LocalStorage) Delete(filename string) error {
+	err := os.Remove(s.path(filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
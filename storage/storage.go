@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"difyserver/config"
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Storage 与 Dify 共用的文件存储，路径使用 Dify 的存储布局，例如 privkeys/<tenant_id>/private.pem
+type Storage interface {
+	Save(filename string, data []byte) error
+	Load(filename string) ([]byte, error)
+	Delete(filename string) error
+}
+
+// Default 根据配置初始化的存储
+var Default Storage
+
+// InitStorage 根据 storage 配置初始化存储，需要与 Dify 的 STORAGE_TYPE 配置指向同一位置
+func InitStorage() error {
+	cfg := config.GlobalConfig.Storage
+	switch cfg.Type {
+	case "", config.StorageTypeLocal:
+		Default = NewLocalStorage(cfg.Local.Path)
+	case config.StorageTypeS3:
+		s, err := NewS3Storage(cfg.S3)
+		if err != nil {
+			return err
+		}
+		Default = s
+	default:
+		return fmt.Errorf("不支持的存储类型: %s", cfg.Type)
+	}
+	return nil
+}
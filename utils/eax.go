@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// Dify 使用 PyCryptodome 的 AES EAX 模式加密模型供应商凭据，Go 标准库没有 EAX，这里按
+// Bellare、Rogaway、Wagner 的 EAX 规范实现：N' = OMAC0(N)，H' = OMAC1(H)，C = CTR(N', M)，Tag = N' ^ OMAC2(C) ^ H'
+
+const eaxTagSize = 16
+
+var errEAXAuthFailed = errors.New("eax: 认证失败")
+
+// eaxSeal 加密并返回密文和 16 字节的认证标签
+func eaxSeal(key, nonce, header, plaintext []byte) ([]byte, []byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := omac(block, 0, nonce)
+	h := omac(block, 1, header)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCTR(block, n).XORKeyStream(ciphertext, plaintext)
+	c := omac(block, 2, ciphertext)
+
+	tag := make([]byte, eaxTagSize)
+	for i := range tag {
+		tag[i] = n[i] ^ h[i] ^ c[i]
+	}
+	return ciphertext, tag, nil
+}
+
+// eaxOpen 校验认证标签并解密
+func eaxOpen(key, nonce, header, ciphertext, tag []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	n := omac(block, 0, nonce)
+	h := omac(block, 1, header)
+	c := omac(block, 2, ciphertext)
+
+	expected := make([]byte, eaxTagSize)
+	for i := range expected {
+		expected[i] = n[i] ^ h[i] ^ c[i]
+	}
+	if len(tag) != eaxTagSize || subtle.ConstantTimeCompare(expected, tag) != 1 {
+		return nil, errEAXAuthFailed
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, n).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+// omac 计算 OMAC_t(data) = CMAC([t]_n || data)
+func omac(block cipher.Block, t byte, data []byte) []byte {
+	msg := make([]byte, aes.BlockSize+len(data))
+	msg[aes.BlockSize-1] = t
+	copy(msg[aes.BlockSize:], data)
+	return cmac(block, msg)
+}
+
+// cmac 按 RFC 4493 计算 AES-CMAC
+func cmac(block cipher.Block, msg []byte) []byte {
+	const bs = aes.BlockSize
+
+	l := make([]byte, bs)
+	block.Encrypt(l, l)
+	k1 := cmacDouble(l)
+	k2 := cmacDouble(k1)
+
+	// 最后一块是完整的块时与 K1 异或，否则补位后与 K2 异或
+	blocks := (len(msg) + bs - 1) / bs
+	if blocks == 0 {
+		blocks = 1
+	}
+	last := make([]byte, bs)
+	rest := msg[(blocks-1)*bs:]
+	if len(rest) == bs {
+		for i := range last {
+			last[i] = rest[i] ^ k1[i]
+		}
+	} else {
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		for i := range last {
+			last[i] ^= k2[i]
+		}
+	}
+
+	x := make([]byte, bs)
+	for i := 0; i < blocks-1; i++ {
+		for j := 0; j < bs; j++ {
+			x[j] ^= msg[i*bs+j]
+		}
+		block.Encrypt(x, x)
+	}
+	for j := 0; j < bs; j++ {
+		x[j] ^= last[j]
+	}
+	block.Encrypt(x, x)
+	return x
+}
+
+// cmacDouble 在 GF(2^128) 上乘以 x
+func cmacDouble(in []byte) []byte {
+	out := make([]byte, len(in))
+	var carry byte
+	for i := len(in) - 1; i >= 0; i-- {
+		out[i] = in[i]<<1 | carry
+		carry = in[i] >> 7
+	}
+	if carry != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("hex 解码失败 %q: %v", s, err)
+	}
+	return b
+}
+
+// RFC 4493 第 4 节的 AES-128 CMAC 测试向量
+func TestCMAC(t *testing.T) {
+	const key = "2b7e151628aed2a6abf7158809cf4f3c"
+	const msg = "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+	tests := []struct {
+		msg string
+		mac string
+	}{
+		{"", "bb1d6929e95937287fa37d129b756746"},
+		{msg[:32], "070a16b46b4d4144f79bdd9dd04a287c"},
+		{msg[:80], "dfa66747de9ae63030ca32611497c827"},
+		{msg, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+
+	block, err := aes.NewCipher(mustHex(t, key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got := cmac(block, mustHex(t, tt.msg))
+		if hex.EncodeToString(got) != tt.mac {
+			t.Errorf("cmac(%d 字节) = %x, 期望 %s", len(tt.msg)/2, got, tt.mac)
+		}
+	}
+}
+
+// Bellare、Rogaway、Wagner《The EAX Mode of Operation》附录中的测试向量，cipher 为密文加 16 字节标签
+var eaxVectors = []struct {
+	msg, key, nonce, header, cipher string
+}{
+	{
+		"",
+		"233952dee4d5ed5f9b9c6d6ff80ff478", "62ec67f9c3a4a407fcb2a8c49031a8b3", "6bfb914fd07eae6b",
+		"e037830e8389f27b025a2d6527e79d01",
+	},
+	{
+		"f7fb",
+		"91945d3f4dcbee0bf45ef52255f095a4", "becaf043b0a23d843194ba972c66debd", "fa3bfd4806eb53fa",
+		"19dd5c4c9331049d0bdab0277408f67967e5",
+	},
+	{
+		"1a47cb4933",
+		"01f74ad64077f2e704c0f60ada3dd523", "70c3db4f0d26368400a10ed05d2bff5e", "234a3463c1264ac6",
+		"d851d5bae03a59f238a23e39199dc9266626c40f80",
+	},
+	{
+		"481c9e39b1",
+		"d07cf6cbb7f313bdde66b727afd3c5e8", "8408dfff3c1a2b1292dc199e46b7d617", "33cce2eabff5a79d",
+		"632a9d131ad4c168a4225d8e1ff755939974a7bede",
+	},
+	{
+		"40d0c07da5e4",
+		"35b6d0580005bbc12b0587124557d2c2", "fdb6b06676eedc5c61d74276e1f8e816", "aeb96eaebe2970e9",
+		"071dfe16c675cb0677e536f73afe6a14b74ee49844dd",
+	},
+	{
+		"8b0a79306c9ce7ed99dae4f87f8dd61636",
+		"7c77d6e813bed5ac98baa417477a2e7d", "1a8c98dcd73d38393b2bf1569deefc19", "65d2017990d62528",
+		"02083e3979da014812f59f11d52630da30137327d10649b0aa6e1c181db617d7f2",
+	},
+}
+
+func TestEAXVectors(t *testing.T) {
+	for i, v := range eaxVectors {
+		key, nonce, header := mustHex(t, v.key), mustHex(t, v.nonce), mustHex(t, v.header)
+		msg, expected := mustHex(t, v.msg), mustHex(t, v.cipher)
+
+		ciphertext, tag, err := eaxSeal(key, nonce, header, msg)
+		if err != nil {
+			t.Fatalf("向量 %d: eaxSeal 失败: %v", i, err)
+		}
+		if got := append(ciphertext, tag...); !bytes.Equal(got, expected) {
+			t.Errorf("向量 %d: eaxSeal = %x, 期望 %x", i, got, expected)
+		}
+
+		split := len(expected) - eaxTagSize
+		plaintext, err := eaxOpen(key, nonce, header, expected[:split], expected[split:])
+		if err != nil {
+			t.Fatalf("向量 %d: eaxOpen 失败: %v", i, err)
+		}
+		if !bytes.Equal(plaintext, msg) {
+			t.Errorf("向量 %d: eaxOpen = %x, 期望 %x", i, plaintext, msg)
+		}
+
+		// 修改标签后必须校验失败
+		badTag := append([]byte(nil), expected[split:]...)
+		badTag[0] ^= 1
+		if _, err := eaxOpen(key, nonce, header, expected[:split], badTag); err != errEAXAuthFailed {
+			t.Errorf("向量 %d: 篡改标签后 eaxOpen 返回 %v, 期望 %v", i, err, errEAXAuthFailed)
+		}
+	}
+}
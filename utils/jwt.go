@@ -1,39 +1,143 @@
 package utils
 
 import (
+	"crypto/rand"
+	"difyserver/config"
+	"errors"
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"log"
 	"time"
 )
 
-var jwtSecret = []byte("dify_secret_key")
+// token 类型
+const (
+	TokenTypeAccess     = "access"
+	TokenTypeRefresh    = "refresh"
+	TokenTypeInvitation = "invitation"
+)
+
+var (
+	jwtKeys         = map[string][]byte{}
+	jwtActiveKey    string
+	accessTokenTTL  = 30 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
 
 type Claims struct {
 	ID    string `json:"id"`
 	Email string `json:"email"`
+	Role  string `json:"role"`
+	Type  string `json:"typ"`
 	jwt.StandardClaims
 }
 
-func GenerateToken(id, email string) (string, error) {
+// InitJWT 从配置加载签名密钥和有效期，需在 config.LoadConfig 之后调用
+func InitJWT() error {
+	cfg := config.GlobalConfig.JWT
+
+	jwtKeys = map[string][]byte{}
+	for _, key := range cfg.Keys {
+		if key.ID == "" || key.Secret == "" {
+			return errors.New("jwt 密钥的 id 和 secret 不能为空")
+		}
+		jwtKeys[key.ID] = []byte(key.Secret)
+	}
+
+	jwtActiveKey = cfg.ActiveKey
+	if jwtActiveKey == "" && len(cfg.Keys) > 0 {
+		jwtActiveKey = cfg.Keys[0].ID
+	}
+
+	// 未配置密钥时生成随机密钥，服务重启后之前签发的 token 全部失效
+	if len(jwtKeys) == 0 {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		jwtActiveKey = "random"
+		jwtKeys[jwtActiveKey] = secret
+		log.Println("未配置 jwt 签名密钥，已使用随机密钥，重启后需重新登录")
+	}
+
+	if _, ok := jwtKeys[jwtActiveKey]; !ok {
+		return errors.New("jwt active_key 不在密钥列表中")
+	}
+
+	if cfg.AccessTokenTTL > 0 {
+		accessTokenTTL = time.Duration(cfg.AccessTokenTTL) * time.Minute
+	}
+	if cfg.RefreshTokenTTL > 0 {
+		refreshTokenTTL = time.Duration(cfg.RefreshTokenTTL) * time.Hour
+	}
+
+	return nil
+}
+
+// GenerateToken 签发访问 token
+func GenerateToken(id, email, role string) (string, error) {
+	return generateToken(id, email, role, TokenTypeAccess, accessTokenTTL)
+}
+
+// GenerateRefreshToken 签发刷新 token，只能用于换取新的访问 token
+func GenerateRefreshToken(id, email, role string) (string, error) {
+	return generateToken(id, email, role, TokenTypeRefresh, refreshTokenTTL)
+}
+
+func generateToken(id, email, role, tokenType string, ttl time.Duration) (string, error) {
 	nowTime := time.Now()
-	expireTime := nowTime.Add(24 * time.Hour)
+	expireTime := nowTime.Add(ttl)
 
 	claims := Claims{
 		ID:    id,
 		Email: email,
+		Role:  role,
+		Type:  tokenType,
 		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.New().String(),
 			ExpiresAt: expireTime.Unix(),
 			IssuedAt:  nowTime.Unix(),
 		},
 	}
 
+	return signToken(claims)
+}
+
+// GenerateInvitationToken 签发邀请链接中的 token，jti 为邀请记录的ID，用于校验邀请是否已使用或撤销
+func GenerateInvitationToken(invitationID, accountID, email string, expiresAt time.Time) (string, error) {
+	claims := Claims{
+		ID:    accountID,
+		Email: email,
+		Type:  TokenTypeInvitation,
+		StandardClaims: jwt.StandardClaims{
+			Id:        invitationID,
+			ExpiresAt: expiresAt.Unix(),
+			IssuedAt:  time.Now().Unix(),
+		},
+	}
+	return signToken(claims)
+}
+
+// signToken 使用当前密钥签名，header 中记录密钥ID
+func signToken(claims Claims) (string, error) {
 	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := tokenClaims.SignedString(jwtSecret)
+	tokenClaims.Header["kid"] = jwtActiveKey
+	token, err := tokenClaims.SignedString(jwtKeys[jwtActiveKey])
 	return token, err
 }
 
+// ParseToken 解析并校验 token，根据 header 中的 kid 选择对应的密钥
 func ParseToken(token string) (*Claims, error) {
 	tokenClaims, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("不支持的签名算法")
+		}
+		kid, _ := token.Header["kid"].(string)
+		secret, ok := jwtKeys[kid]
+		if !ok {
+			return nil, errors.New("未知的签名密钥")
+		}
+		return secret, nil
 	})
 
 	if err != nil {
@@ -44,5 +148,10 @@ func ParseToken(token string) (*Claims, error) {
 		return claims, nil
 	}
 
-	return nil, err
+	return nil, errors.New("无效的 token")
+}
+
+// RefreshTokenTTL 刷新 token 的有效期
+func RefreshTokenTTL() time.Duration {
+	return refreshTokenTTL
 }
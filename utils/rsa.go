@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"difyserver/storage"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// PrivateKeyPath 工作空间私钥在存储中的路径，与 Dify 的 libs/rsa.py 一致
+func PrivateKeyPath(tenantID string) string {
+	return path.Join("privkeys", tenantID, "private.pem")
+}
+
+// GenerateKeyPair 为工作空间生成 2048 位 RSA 密钥对，私钥（PKCS#1 PEM）保存到存储中，返回公钥（PKIX PEM）
+// 生成方式与 Dify 创建工作空间时相同，Dify 用该密钥对加解密模型供应商的凭据
+func GenerateKeyPair(tenantID string) (string, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	if err := storage.Default.Save(PrivateKeyPath(tenantID), privatePEM); err != nil {
+		return "", err
+	}
+
+	// Dify 保存的公钥末尾没有换行
+	return strings.TrimSpace(string(publicPEM)), nil
+}
+
+// hybridPrefix Dify 混合加密数据的前缀，之后依次为 RSA 加密的 AES 密钥、16 字节 nonce、16 字节标签和密文
+var hybridPrefix = []byte("HYBRID:")
+
+// LoadPrivateKey 从存储中读取工作空间的私钥
+func LoadPrivateKey(tenantID string) (*rsa.PrivateKey, error) {
+	data, err := storage.Default.Load(PrivateKeyPath(tenantID))
+	if err != nil {
+		return nil, fmt.Errorf("读取工作空间 %s 的私钥失败: %w", tenantID, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("工作空间 %s 的私钥格式错误", tenantID)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("工作空间 %s 的私钥格式错误: %w", tenantID, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("工作空间 %s 的私钥不是 RSA 密钥", tenantID)
+	}
+	return rsaKey, nil
+}
+
+// ParsePublicKey 解析工作空间的公钥（tenants.encrypt_public_key）
+func ParsePublicKey(publicKeyPEM string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("公钥格式错误")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("公钥不是 RSA 密钥")
+	}
+	return rsaKey, nil
+}
+
+// EncryptToken 与 Dify 的 encrypter.encrypt_token 相同：AES-EAX 加密内容，RSA-OAEP(SHA1) 加密 AES 密钥，结果 base64 编码
+func EncryptToken(publicKey *rsa.PublicKey, token string) (string, error) {
+	aesKey := make([]byte, 16)
+	if _, err := rand.Read(aesKey); err != nil {
+		return "", err
+	}
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	ciphertext, tag, err := eaxSeal(aesKey, nonce, nil, []byte(token))
+	if err != nil {
+		return "", err
+	}
+	encKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, publicKey, aesKey, nil)
+	if err != nil {
+		return "", err
+	}
+
+	data := make([]byte, 0, len(hybridPrefix)+len(encKey)+len(nonce)+len(tag)+len(ciphertext))
+	data = append(data, hybridPrefix...)
+	data = append(data, encKey...)
+	data = append(data, nonce...)
+	data = append(data, tag...)
+	data = append(data, ciphertext...)
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecryptToken 与 Dify 的 encrypter.decrypt_token 相同，同时支持混合加密和早期只用 RSA 加密的数据
+func DecryptToken(privateKey *rsa.PrivateKey, token string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return "", err
+	}
+
+	if !bytes.HasPrefix(data, hybridPrefix) {
+		plaintext, err := rsa.DecryptOAEP(sha1.New(), nil, privateKey, data, nil)
+		if err != nil {
+			return "", err
+		}
+		return string(plaintext), nil
+	}
+
+	data = data[len(hybridPrefix):]
+	size := privateKey.Size()
+	if len(data) < size+32 {
+		return "", errors.New("加密数据长度错误")
+	}
+	aesKey, err := rsa.DecryptOAEP(sha1.New(), nil, privateKey, data[:size], nil)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := eaxOpen(aesKey, data[size:size+16], nil, data[size+32:], data[size+16:size+32])
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncryptedToken 判断值是否像 Dify 加密后的凭据：base64 解码后以 HYBRID: 开头，或为早期 2048 位 RSA 密文
+func IsEncryptedToken(value string) bool {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(data, hybridPrefix) || len(data) == 256
+}
+
+// ObfuscatedToken 与 Dify 的 encrypter.obfuscated_token 相同，只保留前 6 位和后 2 位
+func ObfuscatedToken(token string) string {
+	if token == "" {
+		return token
+	}
+	if len(token) <= 8 {
+		return strings.Repeat("*", 20)
+	}
+	return token[:6] + strings.Repeat("*", 12) + token[len(token)-2:]
+}